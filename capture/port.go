@@ -0,0 +1,98 @@
+package capture
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rehiy/modem/at"
+)
+
+// pendingLimit 绑定设备前最多缓存的记录数，超过后写入端口对应的文件
+const pendingLimit = 1000
+
+// Port 记录读写数据的端口包装
+type Port struct {
+	at.Port
+	recorder *Recorder
+	fallback string
+
+	mu      sync.Mutex
+	w       *writer
+	pending []Record
+}
+
+// Wrap 包装端口，识别设备前的数据先缓存，调用 Bind 后写入设备的抓包文件；
+// 未绑定设备时，数据写入 fallback 对应的文件
+func (r *Recorder) Wrap(port at.Port, fallback, note string) *Port {
+	p := &Port{Port: port, recorder: r, fallback: Key(fallback)}
+	p.record(DirNote, []byte("open "+note))
+	return p
+}
+
+// Bind 绑定设备标识，写入缓存的记录
+func (p *Port) Bind(name string) {
+	p.bind(Key(name))
+}
+
+// bind 绑定抓包文件
+func (p *Port) bind(key string) {
+	w := p.recorder.writer(key)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.w == w {
+		return
+	}
+	p.w = w
+	w.write(p.pending...)
+	p.pending = nil
+}
+
+// record 记录一段数据
+func (p *Port) record(dir byte, b []byte) {
+	r := Record{Time: time.Now(), Dir: dir, Data: append([]byte{}, b...)}
+
+	p.mu.Lock()
+	w := p.w
+	if w == nil {
+		p.pending = append(p.pending, r)
+		full := len(p.pending) >= pendingLimit
+		p.mu.Unlock()
+		if full {
+			p.bind(p.fallback)
+		}
+		return
+	}
+	p.mu.Unlock()
+
+	w.write(r)
+}
+
+// Read 读取数据并记录
+func (p *Port) Read(b []byte) (int, error) {
+	n, err := p.Port.Read(b)
+	if n > 0 {
+		p.record(DirRead, b[:n])
+	}
+	return n, err
+}
+
+// Write 记录数据后写入，保证记录顺序先于设备响应
+func (p *Port) Write(b []byte) (int, error) {
+	p.record(DirWrite, b)
+	return p.Port.Write(b)
+}
+
+// Close 关闭端口，未绑定设备时写入端口对应的文件
+func (p *Port) Close() error {
+	p.record(DirNote, []byte("close"))
+
+	p.mu.Lock()
+	bound := p.w != nil
+	p.mu.Unlock()
+	if !bound {
+		p.bind(p.fallback)
+	}
+	return p.Port.Close()
+}
@@ -0,0 +1,80 @@
+package capture
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 数据方向
+const (
+	DirWrite = '>' // 发往设备
+	DirRead  = '<' // 设备返回
+	DirNote  = '#' // 备注，如端口打开、关闭
+)
+
+// timeLayout 记录时间格式
+const timeLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+// Record 一条抓包记录
+type Record struct {
+	Time time.Time
+	Dir  byte
+	Data []byte
+}
+
+// String 格式化为一行文本，例如 2024-01-02T03:04:05.000000Z > "AT\r\n"
+func (r Record) String() string {
+	return fmt.Sprintf("%s %c %s\n", r.Time.UTC().Format(timeLayout), r.Dir, strconv.Quote(string(r.Data)))
+}
+
+// ParseRecord 解析一行抓包记录
+func ParseRecord(line string) (Record, error) {
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) != 3 || len(parts[1]) != 1 {
+		return Record{}, fmt.Errorf("invalid record: %q", line)
+	}
+
+	t, err := time.Parse(timeLayout, parts[0])
+	if err != nil {
+		return Record{}, fmt.Errorf("invalid record time: %w", err)
+	}
+	dir := parts[1][0]
+	if dir != DirWrite && dir != DirRead && dir != DirNote {
+		return Record{}, fmt.Errorf("invalid record direction: %c", dir)
+	}
+	data, err := strconv.Unquote(parts[2])
+	if err != nil {
+		return Record{}, fmt.Errorf("invalid record data: %w", err)
+	}
+
+	return Record{Time: t, Dir: dir, Data: []byte(data)}, nil
+}
+
+// ReadFile 读取抓包文件中的全部记录
+func ReadFile(name string) ([]Record, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records := []Record{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		r, err := ParseRecord(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,211 @@
+package capture
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileExt 抓包文件扩展名，滚动后的文件追加序号，例如 demo.cap.1
+const fileExt = ".cap"
+
+// unsafeChars 文件名中不允许的字符
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// FileInfo 抓包文件信息
+type FileInfo struct {
+	Name    string    `json:"name"`
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Recorder 按设备记录抓包文件，单个文件超过大小限制时滚动
+type Recorder struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+
+	mu      sync.Mutex
+	writers map[string]*writer
+}
+
+// NewRecorder 创建抓包记录器，maxFiles 为每台设备保留的文件数量
+func NewRecorder(dir string, maxSize int64, maxFiles int) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create capture dir: %w", err)
+	}
+	if maxFiles < 1 {
+		maxFiles = 1
+	}
+	return &Recorder{
+		dir:      dir,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		writers:  map[string]*writer{},
+	}, nil
+}
+
+// Key 将设备名称转换为可用作文件名的标识
+func Key(name string) string {
+	key := strings.Trim(unsafeChars.ReplaceAllString(name, "_"), "._")
+	if key == "" {
+		return "unknown"
+	}
+	return key
+}
+
+// writer 返回设备的抓包文件写入器
+func (r *Recorder) writer(key string) *writer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.writers[key]
+	if !ok {
+		w = &writer{path: filepath.Join(r.dir, key+fileExt), maxSize: r.maxSize, maxFiles: r.maxFiles}
+		r.writers[key] = w
+	}
+	return w
+}
+
+// List 返回抓包文件列表，key 为空时返回全部
+func (r *Recorder) List(key string) ([]FileInfo, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []FileInfo{}
+	for _, e := range entries {
+		k, ok := fileKey(e.Name())
+		if !ok || e.IsDir() || (key != "" && k != key) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, FileInfo{Name: e.Name(), Key: k, Size: info.Size(), ModTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Key != files[j].Key {
+			return files[i].Key < files[j].Key
+		}
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+	return files, nil
+}
+
+// Path 返回抓包文件的完整路径，文件名不能包含目录
+func (r *Recorder) Path(name string) (string, error) {
+	if _, ok := fileKey(name); !ok || filepath.Base(name) != name {
+		return "", fmt.Errorf("invalid capture file: %s", name)
+	}
+	p := filepath.Join(r.dir, name)
+	if _, err := os.Stat(p); err != nil {
+		return "", fmt.Errorf("capture file %s not found", name)
+	}
+	return p, nil
+}
+
+// fileKey 从抓包文件名中提取设备标识
+func fileKey(name string) (string, bool) {
+	i := strings.LastIndex(name, fileExt)
+	if i <= 0 {
+		return "", false
+	}
+	suffix := name[i+len(fileExt):]
+	if suffix != "" && strings.Trim(suffix, ".0123456789") != "" {
+		return "", false
+	}
+	return name[:i], true
+}
+
+// writer 单台设备的抓包文件
+type writer struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	failed bool
+}
+
+// write 追加记录，超过大小限制时滚动文件
+func (w *writer) write(records ...Record) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, r := range records {
+		line := r.String()
+		if err := w.open(int64(len(line))); err == nil {
+			_, err = w.file.WriteString(line)
+			w.size += int64(len(line))
+			w.fail(err)
+		} else {
+			w.fail(err)
+		}
+	}
+}
+
+// open 打开文件，写入 n 字节后超过大小限制时先滚动，调用方需持有锁
+func (w *writer) open(n int64) error {
+	if w.file != nil && w.size > 0 && w.size+n > w.maxSize {
+		w.file.Close()
+		w.file = nil
+		w.rotate()
+	}
+	if w.file != nil {
+		return nil
+	}
+
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.size = f, info.Size()
+	if w.size > 0 && w.size+n > w.maxSize {
+		return w.open(n)
+	}
+	return nil
+}
+
+// rotate 滚动文件：demo.cap -> demo.cap.1 -> demo.cap.2，超出数量的文件被删除
+func (w *writer) rotate() {
+	name := func(i int) string {
+		if i == 0 {
+			return w.path
+		}
+		return fmt.Sprintf("%s.%d", w.path, i)
+	}
+	os.Remove(name(w.maxFiles - 1))
+	for i := w.maxFiles - 2; i >= 0; i-- {
+		os.Rename(name(i), name(i+1))
+	}
+	w.size = 0
+}
+
+// fail 记录写入错误，同一文件只输出一次日志
+func (w *writer) fail(err error) {
+	if err == nil {
+		w.failed = false
+		return
+	}
+	if !w.failed {
+		log.Printf("[Capture] failed to write %s: %v", w.path, err)
+		w.failed = true
+	}
+}
@@ -0,0 +1,193 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplayScheme 回放端口前缀，例如 replay://data/capture/demo.cap
+const ReplayScheme = "replay://"
+
+// replayMaxGap 回放时相邻两条设备输出的最大间隔
+var replayMaxGap = 2 * time.Second
+
+// ReplayPort 回放抓包文件的虚拟端口，按记录中的命令返回对应的设备输出
+type ReplayPort struct {
+	name    string
+	records []Record
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	out    []byte
+	closed bool
+	pos    int      // 下一条待匹配的记录
+	queued []Record // 等待输出的设备数据
+	gen    int      // 输出序列编号，新命令到达时递增
+}
+
+// OpenReplay 打开回放端口，u 为抓包文件路径，可带 replay:// 前缀
+func OpenReplay(u string) (*ReplayPort, error) {
+	name := strings.TrimPrefix(u, ReplayScheme)
+	records, err := ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capture: %w", err)
+	}
+
+	// 忽略备注
+	list := []Record{}
+	for _, r := range records {
+		if r.Dir != DirNote {
+			list = append(list, r)
+		}
+	}
+
+	p := &ReplayPort{name: filepath.Base(name), records: list}
+	p.cond = sync.NewCond(&p.mu)
+
+	// 输出第一条命令之前的设备数据，如开机通知
+	p.mu.Lock()
+	p.queue(p.responses(0), time.Time{})
+	p.mu.Unlock()
+
+	return p, nil
+}
+
+// Read 读取回放输出，无数据时阻塞
+func (p *ReplayPort) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.out) == 0 && !p.closed {
+		p.cond.Wait()
+	}
+	if len(p.out) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, p.out)
+	p.out = p.out[n:]
+	return n, nil
+}
+
+// Write 匹配记录中的命令，输出其后的设备数据
+func (p *ReplayPort) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, fmt.Errorf("port closed")
+	}
+
+	// 上一条命令尚未输出的数据在新命令之前到达
+	for _, r := range p.queued {
+		p.out = append(p.out, r.Data...)
+	}
+	p.queued = nil
+	p.cond.Broadcast()
+
+	// 按顺序匹配，命令顺序不同时向后查找
+	for i := p.pos; i < len(p.records); i++ {
+		r := p.records[i]
+		if r.Dir != DirWrite || !bytes.Equal(r.Data, b) {
+			continue
+		}
+		if skipped := p.countWrites(p.pos, i); skipped > 0 {
+			log.Printf("[Replay] %s: skipped %d commands before %q", p.name, skipped, b)
+		}
+		p.pos = i + 1
+		p.queue(p.responses(p.pos), r.Time)
+		return len(b), nil
+	}
+
+	// 记录之外的命令（如定时检测），使用之前相同命令的响应
+	for i := p.pos - 1; i >= 0; i-- {
+		r := p.records[i]
+		if r.Dir == DirWrite && bytes.Equal(r.Data, b) {
+			p.queue(p.responses(i+1), r.Time)
+			return len(b), nil
+		}
+	}
+
+	log.Printf("[Replay] %s: unexpected command %q", p.name, b)
+	p.queue([]Record{{Dir: DirRead, Data: []byte("\r\nERROR\r\n")}}, time.Time{})
+	return len(b), nil
+}
+
+// Flush 无需刷新
+func (p *ReplayPort) Flush() error {
+	return nil
+}
+
+// Close 关闭端口
+func (p *ReplayPort) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	p.queued = nil
+	p.cond.Broadcast()
+	return nil
+}
+
+// responses 返回从 i 开始到下一条命令之前的设备输出，调用方需持有锁
+func (p *ReplayPort) responses(i int) []Record {
+	list := []Record{}
+	for ; i < len(p.records) && p.records[i].Dir != DirWrite; i++ {
+		list = append(list, p.records[i])
+	}
+	return list
+}
+
+// countWrites 统计区间内的命令数，调用方需持有锁
+func (p *ReplayPort) countWrites(from, to int) int {
+	n := 0
+	for i := from; i < to; i++ {
+		if p.records[i].Dir == DirWrite {
+			n++
+		}
+	}
+	return n
+}
+
+// queue 按原始间隔输出设备数据，间隔不超过 replayMaxGap，调用方需持有锁
+func (p *ReplayPort) queue(list []Record, since time.Time) {
+	if len(list) == 0 {
+		return
+	}
+	p.gen++
+	p.queued = list
+	go p.play(p.gen, since)
+}
+
+// play 依次输出队列中的数据，新命令到达后停止
+func (p *ReplayPort) play(gen int, prev time.Time) {
+	for {
+		p.mu.Lock()
+		if p.gen != gen || len(p.queued) == 0 {
+			p.mu.Unlock()
+			return
+		}
+		next := p.queued[0].Time
+		p.mu.Unlock()
+
+		delay := time.Duration(0)
+		if !prev.IsZero() && !next.IsZero() {
+			delay = min(max(next.Sub(prev), 0), replayMaxGap)
+		}
+		time.Sleep(delay)
+		prev = next
+
+		p.mu.Lock()
+		if p.gen == gen && len(p.queued) > 0 {
+			p.out = append(p.out, p.queued[0].Data...)
+			p.queued = p.queued[1:]
+			p.cond.Broadcast()
+		}
+		p.mu.Unlock()
+	}
+}
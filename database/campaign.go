@@ -0,0 +1,154 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/rehiy/web-modem/models"
+)
+
+// CreateCampaign 创建群发任务及每个收件人的发送记录和短信任务，records 与 jobs 一一对应
+func CreateCampaign(campaign *models.Campaign, records []models.Sms, jobs []models.SmsJob) error {
+	if len(records) != len(jobs) {
+		return fmt.Errorf("records and jobs mismatch")
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(campaign).Error; err != nil {
+			return fmt.Errorf("failed to create campaign: %w", err)
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err := tx.CreateInBatches(records, 100).Error; err != nil {
+			return fmt.Errorf("failed to save Sms: %w", err)
+		}
+		for i := range jobs {
+			jobs[i].SmsID = records[i].ID
+			jobs[i].CampaignID = campaign.ID
+		}
+		if err := tx.CreateInBatches(jobs, 100).Error; err != nil {
+			return fmt.Errorf("failed to create sms jobs: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetCampaign 根据ID获取群发任务
+func GetCampaign(id int) (*models.Campaign, error) {
+	var campaign models.Campaign
+	result := db.First(&campaign, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("campaign not found")
+		}
+		return nil, fmt.Errorf("failed to get campaign: %w", result.Error)
+	}
+	return &campaign, nil
+}
+
+// GetCampaignList 获取所有群发任务
+func GetCampaignList() ([]models.Campaign, error) {
+	var campaigns []models.Campaign
+	if err := db.Order("id DESC").Find(&campaigns).Error; err != nil {
+		return nil, fmt.Errorf("failed to get campaign list: %w", err)
+	}
+	return campaigns, nil
+}
+
+// UpdateCampaignStatus 在群发任务仍处于 from 状态时改为 to，状态已改变时返回 false
+func UpdateCampaignStatus(id int, from, to string) (bool, error) {
+	result := db.Model(&models.Campaign{}).Where("id = ? AND status = ?", id, from).Update("status", to)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update campaign: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// SetCampaignJobsStatus 将群发任务中处于 from 状态的短信任务改为 to，smsStatus 不为空时同时更新发送记录，返回修改的数量
+func SetCampaignJobsStatus(id int, from []string, to, smsStatus string) (int, error) {
+	var count int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		jobs := func() *gorm.DB {
+			return tx.Model(&models.SmsJob{}).Where("campaign_id = ? AND status IN ?", id, from)
+		}
+		if smsStatus != "" {
+			err := tx.Model(&models.Sms{}).
+				Where("id IN (?)", jobs().Select("sms_id")).
+				Update("status", smsStatus).Error
+			if err != nil {
+				return err
+			}
+		}
+		result := jobs().Update("status", to)
+		count = int(result.RowsAffected)
+		return result.Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to update campaign jobs: %w", err)
+	}
+	return count, nil
+}
+
+// GetCampaignStats 统计群发任务中各状态的短信任务数量
+func GetCampaignStats(id int) (map[string]int, error) {
+	var rows []struct {
+		Status string
+		Count  int
+	}
+	err := db.Model(&models.SmsJob{}).Select("status, COUNT(*) AS count").
+		Where("campaign_id = ?", id).Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count campaign jobs: %w", err)
+	}
+
+	stats := map[string]int{}
+	for _, r := range rows {
+		stats[r.Status] = r.Count
+	}
+	return stats, nil
+}
+
+// GetCampaignRecipients 获取群发任务中每个收件人的发送结果
+func GetCampaignRecipients(id int) ([]models.CampaignRecipient, error) {
+	var jobs []models.SmsJob
+	if err := db.Where("campaign_id = ?", id).Order("id").Find(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("failed to query sms jobs: %w", err)
+	}
+
+	ids := make([]int, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.SmsID
+	}
+	var records []models.Sms
+	if err := db.Where("id IN ?", ids).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to query Sms: %w", err)
+	}
+	byID := map[int]*models.Sms{}
+	for i := range records {
+		byID[records[i].ID] = &records[i]
+	}
+
+	list := make([]models.CampaignRecipient, len(jobs))
+	for i, job := range jobs {
+		r := models.CampaignRecipient{
+			JobID:     job.ID,
+			SmsID:     job.SmsID,
+			ModemName: job.ModemName,
+			Number:    job.Number,
+			Content:   job.Content,
+			JobStatus: job.Status,
+			Error:     job.LastError,
+		}
+		if sms := byID[job.SmsID]; sms != nil {
+			r.SmsStatus = sms.Status
+			r.SentAt = sms.SentAt
+			r.DeliveredAt = sms.DeliveredAt
+			if sms.Error != "" {
+				r.Error = sms.Error
+			}
+		}
+		list[i] = r
+	}
+	return list, nil
+}
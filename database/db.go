@@ -78,6 +78,13 @@ func createTables() error {
 		&models.Sms{},
 		&models.Webhook{},
 		&models.Setting{},
+		&models.Modem{},
+		&models.PortSetting{},
+		&models.ModemProfile{},
+		&models.SmsJob{},
+		&models.Campaign{},
+		&models.SmsRoute{},
+		&models.ReceivedPdu{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to auto migrate: %w", err)
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/rehiy/web-modem/models"
+)
+
+// ErrAliasExists 别名已被其他设备使用
+var ErrAliasExists = errors.New("alias is already used by another modem")
+
+// GetModem 根据IMEI获取设备信息
+func GetModem(imei string) (*models.Modem, error) {
+	var modem models.Modem
+	result := db.First(&modem, "imei = ?", imei)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("modem not found")
+		}
+		return nil, fmt.Errorf("failed to get modem: %w", result.Error)
+	}
+	return &modem, nil
+}
+
+// GetModemByAlias 根据别名获取设备信息
+func GetModemByAlias(alias string) (*models.Modem, error) {
+	var modem models.Modem
+	result := db.First(&modem, "alias = ?", alias)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("modem not found")
+		}
+		return nil, fmt.Errorf("failed to get modem: %w", result.Error)
+	}
+	return &modem, nil
+}
+
+// GetModemList 获取所有已知设备
+func GetModemList() ([]models.Modem, error) {
+	var modems []models.Modem
+	result := db.Order("last_seen DESC").Find(&modems)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query modems: %w", result.Error)
+	}
+	return modems, nil
+}
+
+// TouchModem 记录设备上线信息，不修改别名
+func TouchModem(imei, iccid, port string) (*models.Modem, error) {
+	modem := models.Modem{IMEI: imei}
+	attrs := models.Modem{ICCID: iccid, Port: port, LastSeen: time.Now()}
+	result := db.Where(models.Modem{IMEI: imei}).Assign(attrs).FirstOrCreate(&modem)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to save modem: %w", result.Error)
+	}
+	return &modem, nil
+}
+
+// SetModemAlias 设置设备别名，别名已被其他设备使用时返回 ErrAliasExists
+func SetModemAlias(imei, alias string) error {
+	found := true
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if alias != "" {
+			var count int64
+			err := tx.Model(&models.Modem{}).Where("alias = ? AND imei <> ?", alias, imei).Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrAliasExists
+			}
+		}
+		result := tx.Model(&models.Modem{}).Where("imei = ?", imei).Update("alias", alias)
+		found = result.RowsAffected > 0
+		return result.Error
+	})
+	if errors.Is(err, ErrAliasExists) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set modem alias: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("modem not found")
+	}
+	return nil
+}
+
+// SetModemSmsPolicy 设置设备上短信的删除策略
+func SetModemSmsPolicy(imei, policy string, keep int) error {
+	result := db.Model(&models.Modem{}).Where("imei = ?", imei).
+		Select("sms_delete", "sms_keep").
+		Updates(&models.Modem{SmsDelete: policy, SmsKeep: keep})
+	if result.Error != nil {
+		return fmt.Errorf("failed to set modem sms policy: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("modem not found")
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/rehiy/web-modem/models"
+)
+
+// GetPortSetting 获取端口串口参数
+func GetPortSetting(port string) (*models.PortSetting, error) {
+	var setting models.PortSetting
+	result := db.First(&setting, "port = ?", port)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("port setting not found")
+		}
+		return nil, fmt.Errorf("failed to get port setting: %w", result.Error)
+	}
+	return &setting, nil
+}
+
+// GetPortSettingList 获取所有端口串口参数
+func GetPortSettingList() ([]models.PortSetting, error) {
+	var settings []models.PortSetting
+	result := db.Order("port").Find(&settings)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query port settings: %w", result.Error)
+	}
+	return settings, nil
+}
+
+// SavePortSetting 保存端口串口参数
+func SavePortSetting(setting *models.PortSetting) error {
+	result := db.Save(setting)
+	if result.Error != nil {
+		return fmt.Errorf("failed to save port setting: %w", result.Error)
+	}
+	return nil
+}
+
+// DeletePortSetting 删除端口串口参数
+func DeletePortSetting(port string) error {
+	result := db.Delete(&models.PortSetting{}, "port = ?", port)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete port setting: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("port setting not found")
+	}
+	return nil
+}
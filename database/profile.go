@@ -0,0 +1,65 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/rehiy/web-modem/models"
+)
+
+// CreateProfile 创建设备配置档案
+func CreateProfile(profile *models.ModemProfile) error {
+	result := db.Create(profile)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create profile: %w", result.Error)
+	}
+	return nil
+}
+
+// UpdateProfile 更新设备配置档案
+func UpdateProfile(profile *models.ModemProfile) error {
+	result := db.Save(profile)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update profile: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("profile not found")
+	}
+	return nil
+}
+
+// DeleteProfile 删除设备配置档案
+func DeleteProfile(id int) error {
+	result := db.Delete(&models.ModemProfile{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete profile: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("profile not found")
+	}
+	return nil
+}
+
+// GetProfile 根据ID获取设备配置档案
+func GetProfile(id int) (*models.ModemProfile, error) {
+	var profile models.ModemProfile
+	result := db.First(&profile, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("profile not found")
+		}
+		return nil, fmt.Errorf("failed to get profile: %w", result.Error)
+	}
+	return &profile, nil
+}
+
+// GetProfileList 获取所有自定义设备配置档案
+func GetProfileList() ([]models.ModemProfile, error) {
+	var profiles []models.ModemProfile
+	result := db.Order("id").Find(&profiles)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query profiles: %w", result.Error)
+	}
+	return profiles, nil
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"github.com/rehiy/web-modem/models"
+)
+
+// HasReceivedPdu 检查设备收到的短信分段是否已完成处理
+func HasReceivedPdu(modemName, fingerprint string) (bool, error) {
+	var count int64
+	err := db.Model(&models.ReceivedPdu{}).
+		Where("modem_name = ? AND fingerprint = ? AND done = ?", modemName, fingerprint, true).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to query received pdu: %w", err)
+	}
+	return count > 0, nil
+}
+
+// IsArchivedPdu 检查短信分段是否已完成处理并保存到数据库
+func IsArchivedPdu(modemName, fingerprint string) (bool, error) {
+	var count int64
+	err := db.Model(&models.ReceivedPdu{}).
+		Where("modem_name = ? AND fingerprint = ? AND done = ? AND sms_id > 0", modemName, fingerprint, true).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to query received pdu: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetReceivedPdu 获取短信分段上次处理的结果，包括已保存的短信记录和已成功触发的 Webhook，未处理过时返回空记录
+func GetReceivedPdu(modemName string, fingerprints []string) (*models.ReceivedPdu, error) {
+	var list []models.ReceivedPdu
+	err := db.Where("modem_name = ? AND fingerprint IN ?", modemName, fingerprints).Find(&list).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query received pdu: %w", err)
+	}
+
+	// 长短信各分段的记录相同，分段先后到达时以记录最多的为准
+	pdu := &models.ReceivedPdu{ModemName: modemName}
+	for _, p := range list {
+		if p.SmsID > pdu.SmsID {
+			pdu.SmsID = p.SmsID
+		}
+		if len(p.Hooked) > len(pdu.Hooked) {
+			pdu.Hooked = p.Hooked
+		}
+	}
+	return pdu, nil
+}
+
+// SaveReceivedPdus 记录短信分段的处理结果，hooked 为已成功触发的 Webhook ID
+func SaveReceivedPdus(modemName string, fingerprints []string, smsID int, hooked string, done bool) error {
+	if len(fingerprints) == 0 {
+		return nil
+	}
+	list := make([]models.ReceivedPdu, len(fingerprints))
+	for i, fp := range fingerprints {
+		list[i] = models.ReceivedPdu{ModemName: modemName, Fingerprint: fp, SmsID: smsID, Hooked: hooked, Done: done}
+	}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "modem_name"}, {Name: "fingerprint"}},
+		DoUpdates: clause.AssignmentColumns([]string{"sms_id", "hooked", "done"}),
+	}).Create(&list).Error
+	if err != nil {
+		return fmt.Errorf("failed to save received pdus: %w", err)
+	}
+	return nil
+}
+
+// PruneReceivedPdus 删除设备早于 before 的分段记录，keep 为仍在设备上的分段指纹，这些记录保留，返回删除的数量
+func PruneReceivedPdus(modemName string, before time.Time, keep []string) (int, error) {
+	query := db.Where("modem_name = ? AND created_at < ?", modemName, before)
+	if len(keep) > 0 {
+		query = query.Where("fingerprint NOT IN ?", keep)
+	}
+	result := query.Delete(&models.ReceivedPdu{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to prune received pdus: %w", result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/rehiy/web-modem/models"
+)
+
+// CreateSmsRoute 创建短信路由规则
+func CreateSmsRoute(route *models.SmsRoute) error {
+	result := db.Create(route)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create sms route: %w", result.Error)
+	}
+	return nil
+}
+
+// UpdateSmsRoute 更新短信路由规则
+func UpdateSmsRoute(route *models.SmsRoute) error {
+	result := db.Save(route)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update sms route: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("sms route not found")
+	}
+	return nil
+}
+
+// DeleteSmsRoute 删除短信路由规则
+func DeleteSmsRoute(id int) error {
+	result := db.Delete(&models.SmsRoute{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete sms route: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("sms route not found")
+	}
+	return nil
+}
+
+// GetSmsRoute 根据ID获取短信路由规则
+func GetSmsRoute(id int) (*models.SmsRoute, error) {
+	var route models.SmsRoute
+	result := db.First(&route, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("sms route not found")
+		}
+		return nil, fmt.Errorf("failed to get sms route: %w", result.Error)
+	}
+	return &route, nil
+}
+
+// GetSmsRouteList 获取所有短信路由规则
+func GetSmsRouteList() ([]models.SmsRoute, error) {
+	var routes []models.SmsRoute
+	result := db.Order("prefix, id").Find(&routes)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query sms routes: %w", result.Error)
+	}
+	return routes, nil
+}
+
+// GetEnabledSmsRoutes 获取所有启用的短信路由规则
+func GetEnabledSmsRoutes() ([]models.SmsRoute, error) {
+	var routes []models.SmsRoute
+	result := db.Where("enabled = ?", true).Order("prefix, id").Find(&routes)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query enabled sms routes: %w", result.Error)
+	}
+	return routes, nil
+}
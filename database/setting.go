@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/rehiy/web-modem/models"
 )
@@ -71,11 +72,197 @@ func SetWebhookEnabled(enabled bool) error {
 	return nil
 }
 
+// GetSmsRateLimit 获取每台设备每分钟最多发送的短信数量，0 表示不限制
+func GetSmsRateLimit() int {
+	var setting models.Setting
+	result := db.Where("key = ?", "sms_rate_limit").First(&setting)
+	if result.Error != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(setting.Value)
+	return n
+}
+
+// SetSmsRateLimit 设置每台设备每分钟最多发送的短信数量
+func SetSmsRateLimit(limit int) error {
+	setting := models.Setting{Key: "sms_rate_limit", Value: strconv.Itoa(limit)}
+	result := db.Where(models.Setting{Key: "sms_rate_limit"}).Assign(setting).FirstOrCreate(&setting)
+	if result.Error != nil {
+		return fmt.Errorf("failed to set sms_rate_limit: %w", result.Error)
+	}
+	return nil
+}
+
+// IsStatusReportEnabled 检查发送短信时是否请求状态报告
+func IsStatusReportEnabled() bool {
+	var setting models.Setting
+	result := db.Where("key = ?", "status_report").First(&setting)
+	if result.Error != nil {
+		return false
+	}
+	return setting.Value == "true"
+}
+
+// SetStatusReportEnabled 设置发送短信时是否请求状态报告
+func SetStatusReportEnabled(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+
+	setting := models.Setting{Key: "status_report", Value: value}
+	result := db.Where(models.Setting{Key: "status_report"}).Assign(setting).FirstOrCreate(&setting)
+	if result.Error != nil {
+		return fmt.Errorf("failed to set status_report: %w", result.Error)
+	}
+	return nil
+}
+
+// GetSmsRoutePolicy 获取未指定设备时选择设备的策略
+func GetSmsRoutePolicy() string {
+	var setting models.Setting
+	result := db.Where("key = ?", "sms_route_policy").First(&setting)
+	if result.Error != nil || setting.Value == "" {
+		return models.RoutePolicyRoundRobin
+	}
+	return setting.Value
+}
+
+// SetSmsRoutePolicy 设置未指定设备时选择设备的策略
+func SetSmsRoutePolicy(policy string) error {
+	setting := models.Setting{Key: "sms_route_policy", Value: policy}
+	result := db.Where(models.Setting{Key: "sms_route_policy"}).Assign(setting).FirstOrCreate(&setting)
+	if result.Error != nil {
+		return fmt.Errorf("failed to set sms_route_policy: %w", result.Error)
+	}
+	return nil
+}
+
+// IsIngestStoredEnabled 检查是否处理设备上已存储的短信
+func IsIngestStoredEnabled() bool {
+	var setting models.Setting
+	result := db.Where("key = ?", "ingest_stored").First(&setting)
+	if result.Error != nil {
+		return false
+	}
+	return setting.Value == "true"
+}
+
+// SetIngestStoredEnabled 设置是否处理设备上已存储的短信
+func SetIngestStoredEnabled(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+
+	setting := models.Setting{Key: "ingest_stored", Value: value}
+	result := db.Where(models.Setting{Key: "ingest_stored"}).Assign(setting).FirstOrCreate(&setting)
+	if result.Error != nil {
+		return fmt.Errorf("failed to set ingest_stored: %w", result.Error)
+	}
+	return nil
+}
+
+// GetIngestInterval 获取定期处理设备上已存储短信的间隔（秒），0 表示只在连接时处理
+func GetIngestInterval() int {
+	var setting models.Setting
+	result := db.Where("key = ?", "ingest_interval").First(&setting)
+	if result.Error != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(setting.Value)
+	return n
+}
+
+// SetIngestInterval 设置定期处理设备上已存储短信的间隔（秒）
+func SetIngestInterval(seconds int) error {
+	setting := models.Setting{Key: "ingest_interval", Value: strconv.Itoa(seconds)}
+	result := db.Where(models.Setting{Key: "ingest_interval"}).Assign(setting).FirstOrCreate(&setting)
+	if result.Error != nil {
+		return fmt.Errorf("failed to set ingest_interval: %w", result.Error)
+	}
+	return nil
+}
+
+// GetSmsDeleteAfter 获取删除设备上短信的时机
+func GetSmsDeleteAfter() string {
+	var setting models.Setting
+	result := db.Where("key = ?", "sms_delete_after").First(&setting)
+	if result.Error != nil || setting.Value == "" {
+		return models.DeleteAfterStored
+	}
+	return setting.Value
+}
+
+// SetSmsDeleteAfter 设置删除设备上短信的时机
+func SetSmsDeleteAfter(after string) error {
+	setting := models.Setting{Key: "sms_delete_after", Value: after}
+	result := db.Where(models.Setting{Key: "sms_delete_after"}).Assign(setting).FirstOrCreate(&setting)
+	if result.Error != nil {
+		return fmt.Errorf("failed to set sms_delete_after: %w", result.Error)
+	}
+	return nil
+}
+
+// GetStorageWarnPercent 获取短信存储使用率的告警阈值（百分比），0 表示不告警
+func GetStorageWarnPercent() int {
+	var setting models.Setting
+	result := db.Where("key = ?", "storage_warn_percent").First(&setting)
+	if result.Error != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(setting.Value)
+	return n
+}
+
+// SetStorageWarnPercent 设置短信存储使用率的告警阈值（百分比）
+func SetStorageWarnPercent(percent int) error {
+	setting := models.Setting{Key: "storage_warn_percent", Value: strconv.Itoa(percent)}
+	result := db.Where(models.Setting{Key: "storage_warn_percent"}).Assign(setting).FirstOrCreate(&setting)
+	if result.Error != nil {
+		return fmt.Errorf("failed to set storage_warn_percent: %w", result.Error)
+	}
+	return nil
+}
+
+// IsStoragePurgeEnabled 检查短信存储超过告警阈值时是否自动删除已归档的短信
+func IsStoragePurgeEnabled() bool {
+	var setting models.Setting
+	result := db.Where("key = ?", "storage_purge").First(&setting)
+	if result.Error != nil {
+		return false
+	}
+	return setting.Value == "true"
+}
+
+// SetStoragePurgeEnabled 设置短信存储超过告警阈值时是否自动删除已归档的短信
+func SetStoragePurgeEnabled(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+
+	setting := models.Setting{Key: "storage_purge", Value: value}
+	result := db.Where(models.Setting{Key: "storage_purge"}).Assign(setting).FirstOrCreate(&setting)
+	if result.Error != nil {
+		return fmt.Errorf("failed to set storage_purge: %w", result.Error)
+	}
+	return nil
+}
+
 // InitDefaultSettings 初始化默认设置
 func InitDefaultSettings() error {
 	defaultSettings := map[string]string{
-		"smsdb_enabled":   "true",
-		"webhook_enabled": "false",
+		"smsdb_enabled":        "true",
+		"webhook_enabled":      "false",
+		"sms_rate_limit":       "10",
+		"status_report":        "true",
+		"sms_route_policy":     models.RoutePolicyRoundRobin,
+		"ingest_stored":        "false",
+		"ingest_interval":      "300",
+		"sms_delete_after":     models.DeleteAfterStored,
+		"storage_warn_percent": "80",
+		"storage_purge":        "false",
 	}
 
 	for key, value := range defaultSettings {
@@ -27,6 +27,70 @@ func CreateSms(sms *models.Sms) error {
 	return nil
 }
 
+// UpdateSmsStatus 更新发送短信的状态、消息参考号和错误信息
+func UpdateSmsStatus(sms *models.Sms) error {
+	err := db.Model(sms).
+		Select("status", "message_refs", "reported", "error", "sent_at", "delivered_at").
+		Updates(sms).Error
+	if err != nil {
+		return fmt.Errorf("failed to update Sms status: %w", err)
+	}
+	return nil
+}
+
+// UpdateSmsSendStatus 更新发送短信的状态、消息参考号和错误信息，不修改状态报告的结果
+func UpdateSmsSendStatus(sms *models.Sms) error {
+	err := db.Model(sms).
+		Select("status", "message_refs", "error", "sent_at").
+		Updates(sms).Error
+	if err != nil {
+		return fmt.Errorf("failed to update Sms status: %w", err)
+	}
+	return nil
+}
+
+// UpdateSmsSender 更新发送记录使用的设备
+func UpdateSmsSender(id int, modemName, sendNumber, iccid string) error {
+	err := db.Model(&models.Sms{ID: id}).
+		Select("modem_name", "send_number", "icc_id").
+		Updates(&models.Sms{ModemName: modemName, SendNumber: sendNumber, ICCID: iccid}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update Sms sender: %w", err)
+	}
+	return nil
+}
+
+// LastSentModem 返回最近成功发送给该号码的设备，没有时返回空字符串
+func LastSentModem(number string) (string, error) {
+	var list []models.Sms
+	err := db.Select("modem_name").
+		Where("receive_number = ? AND direction = ? AND status IN ?", number, "out",
+			[]string{models.SmsStatusSent, models.SmsStatusDelivered}).
+		Order("id DESC").Limit(1).Find(&list).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to query Sms: %w", err)
+	}
+	if len(list) == 0 {
+		return "", nil
+	}
+	return list[0].ModemName, nil
+}
+
+// FindSmsByMessageRef 查找设备最近发送的包含指定消息参考号、等待状态报告的短信
+func FindSmsByMessageRef(modemName string, mr int) (*models.Sms, error) {
+	var list []models.Sms
+	err := db.Where("modem_name = ? AND direction = ? AND status = ?", modemName, "out", models.SmsStatusSent).
+		Where("',' || message_refs || ',' LIKE ?", fmt.Sprintf("%%,%d,%%", mr)).
+		Order("sent_at DESC, id DESC").Limit(1).Find(&list).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Sms: %w", err)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("Sms not found")
+	}
+	return &list[0], nil
+}
+
 // DeleteSms 根据数据库ID删除短信
 func DeleteSms(id int) error {
 	ret := db.Delete(&models.Sms{}, id)
@@ -80,6 +144,9 @@ func GetSmsList(filter *models.SmsFilter) ([]models.Sms, int, error) {
 	if filter.ModemName != "" {
 		query = query.Where("modem_name = ?", filter.ModemName)
 	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
 	if !filter.StartTime.IsZero() {
 		query = query.Where("receive_time >= ?", filter.StartTime)
 	}
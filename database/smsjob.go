@@ -0,0 +1,288 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/rehiy/web-modem/models"
+)
+
+// CreateSmsJob 创建发送记录和短信任务
+func CreateSmsJob(sms *models.Sms, job *models.SmsJob) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(sms).Error; err != nil {
+			return fmt.Errorf("failed to save Sms: %w", err)
+		}
+		job.SmsID = sms.ID
+		if err := tx.Create(job).Error; err != nil {
+			return fmt.Errorf("failed to create sms job: %w", err)
+		}
+		return nil
+	})
+}
+
+// ClaimSmsJob 将已到期的待发送任务标记为正在发送并增加发送次数，成功时重新读取任务；
+// 任务已不是待发送状态（如已取消）、已改为其他设备或定时发送时间已被修改时返回 false
+func ClaimSmsJob(job *models.SmsJob, now time.Time) (bool, error) {
+	result := db.Model(&models.SmsJob{}).
+		Where("id = ? AND status = ? AND modem_name = ? AND next_run_at <= ?", job.ID, models.SmsJobPending, job.ModemName, now).
+		Updates(map[string]any{"status": models.SmsJobSending, "attempts": gorm.Expr("attempts + 1")})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim sms job: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	if err := db.First(job, job.ID).Error; err != nil {
+		return false, fmt.Errorf("failed to get sms job: %w", err)
+	}
+	return true, nil
+}
+
+// UpdateSmsJobFrom 在任务仍处于 from 状态时更新 columns 指定的字段，任务状态已改变时返回 false；
+// 任务改回待发送时，所属群发任务已暂停或取消的改为相应状态，job.Status 为实际保存的状态
+func UpdateSmsJobFrom(job *models.SmsJob, from string, columns ...string) (bool, error) {
+	var saved bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(job).Where("status = ?", from).
+			Select(append(columns, "updated_at")).Updates(job)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		saved = true
+		if job.Status != models.SmsJobPending || job.CampaignID == 0 {
+			return nil
+		}
+		campaign := tx.Model(&models.Campaign{}).Select("status").Where("id = ?", job.CampaignID)
+		err := tx.Model(&models.SmsJob{}).Where("id = ? AND status = ?", job.ID, models.SmsJobPending).
+			Update("status", gorm.Expr("CASE (?) WHEN ? THEN ? WHEN ? THEN ? ELSE status END", campaign,
+				models.CampaignPaused, models.SmsJobPaused, models.CampaignCancelled, models.SmsJobCancelled)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(job).Select("status").First(job).Error
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to update sms job: %w", err)
+	}
+	return saved, nil
+}
+
+// UpdateDueSmsJob 在任务仍为已到期的待发送任务时更新 columns 指定的字段，任务状态或定时发送时间已改变时返回 false
+func UpdateDueSmsJob(job *models.SmsJob, now time.Time, columns ...string) (bool, error) {
+	result := db.Model(job).Where("status = ? AND next_run_at <= ?", models.SmsJobPending, now).
+		Select(append(columns, "updated_at")).Updates(job)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update sms job: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetSmsJob 根据ID获取短信任务
+func GetSmsJob(id int) (*models.SmsJob, error) {
+	var job models.SmsJob
+	result := db.First(&job, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("sms job not found")
+		}
+		return nil, fmt.Errorf("failed to get sms job: %w", result.Error)
+	}
+	return &job, nil
+}
+
+// GetSmsJobList 查询短信任务列表
+func GetSmsJobList(filter *models.SmsJobFilter) ([]models.SmsJob, int, error) {
+	query := db.Model(&models.SmsJob{})
+
+	if filter.ModemName != "" {
+		query = query.Where("modem_name = ?", filter.ModemName)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Scheduled {
+		query = query.Where("send_at IS NOT NULL")
+	}
+
+	// 查询总数
+	var total int64
+	countQuery := query.Session(&gorm.Session{})
+	if err := countQuery.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count sms jobs: %w", err)
+	}
+
+	// 查询列表
+	order := "id DESC"
+	if filter.Scheduled {
+		order = "send_at, id"
+	}
+
+	var jobs []models.SmsJob
+	err := query.Order(order).Limit(filter.Limit).Offset(filter.Offset).Find(&jobs).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query sms jobs: %w", err)
+	}
+
+	return jobs, int(total), nil
+}
+
+// NextSmsJob 返回设备下一条到期的待发送任务，没有时返回 nil
+func NextSmsJob(modemName string, now time.Time) (*models.SmsJob, error) {
+	var jobs []models.SmsJob
+	err := db.Where("modem_name = ? AND status = ? AND next_run_at <= ?", modemName, models.SmsJobPending, now).
+		Order("next_run_at, id").Limit(1).Find(&jobs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sms jobs: %w", err)
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	return &jobs[0], nil
+}
+
+// NextSmsJobTime 返回设备最早的待发送任务的执行时间，没有时返回 nil
+func NextSmsJobTime(modemName string) (*time.Time, error) {
+	var jobs []models.SmsJob
+	err := db.Select("next_run_at").Where("modem_name = ? AND status = ?", modemName, models.SmsJobPending).
+		Order("next_run_at").Limit(1).Find(&jobs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sms jobs: %w", err)
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	return &jobs[0].NextRunAt, nil
+}
+
+// DueAutoRouteSmsJobs 返回设备上已到期、由路由策略选择设备的待发送任务
+func DueAutoRouteSmsJobs(modemName string, now time.Time, limit int) ([]models.SmsJob, error) {
+	var jobs []models.SmsJob
+	err := db.Where("modem_name = ? AND status = ? AND auto_route = ? AND next_run_at <= ?", modemName, models.SmsJobPending, true, now).
+		Order("next_run_at, id").Limit(limit).Find(&jobs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sms jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// CountActiveSmsJobs 统计每台设备等待发送和正在发送的任务数量
+func CountActiveSmsJobs() (map[string]int, error) {
+	var rows []struct {
+		ModemName string
+		Count     int
+	}
+	err := db.Model(&models.SmsJob{}).Select("modem_name, COUNT(*) AS count").
+		Where("status IN ?", []string{models.SmsJobPending, models.SmsJobSending}).
+		Group("modem_name").Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count sms jobs: %w", err)
+	}
+
+	counts := map[string]int{}
+	for _, r := range rows {
+		counts[r.ModemName] = r.Count
+	}
+	return counts, nil
+}
+
+// RescheduleSmsJob 修改等待中的短信任务的定时发送时间，latePolicy 为空时保持不变
+func RescheduleSmsJob(id int, sendAt time.Time, latePolicy string) (*models.SmsJob, error) {
+	job, err := GetSmsJob(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]any{"send_at": sendAt, "next_run_at": sendAt}
+	if latePolicy != "" {
+		updates["late_policy"] = latePolicy
+	}
+	result := db.Model(&models.SmsJob{}).
+		Where("id = ? AND status = ?", id, models.SmsJobPending).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to reschedule sms job: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("sms job is %s, only pending jobs can be rescheduled", job.Status)
+	}
+
+	return GetSmsJob(id)
+}
+
+// ExpireSmsJobs 将定时发送时间早于 before、尚未发送过的过期策略任务标记为过期，返回过期的数量
+func ExpireSmsJobs(before time.Time) (int, error) {
+	var count int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		expired := func() *gorm.DB {
+			return tx.Model(&models.SmsJob{}).
+				Where("status = ? AND late_policy = ? AND attempts = 0", models.SmsJobPending, models.LatePolicyExpire).
+				Where("send_at IS NOT NULL AND send_at < ?", before)
+		}
+		err := tx.Model(&models.Sms{}).
+			Where("id IN (?)", expired().Select("sms_id")).
+			Updates(map[string]any{"status": models.SmsStatusExpired, "error": "missed scheduled time"}).Error
+		if err != nil {
+			return err
+		}
+		result := expired().Updates(map[string]any{"status": models.SmsJobExpired, "last_error": "missed scheduled time"})
+		count = int(result.RowsAffected)
+		return result.Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire sms jobs: %w", err)
+	}
+	return count, nil
+}
+
+// CancelSmsJob 取消等待中的短信任务，任务和短信状态在同一事务中更新
+func CancelSmsJob(id int) (*models.SmsJob, error) {
+	job, err := GetSmsJob(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.SmsJob{}).
+			Where("id = ? AND status = ?", id, models.SmsJobPending).
+			Update("status", models.SmsJobCancelled)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		cancelled = true
+		return tx.Model(&models.Sms{}).Where("id = ?", job.SmsID).Update("status", models.SmsStatusCancelled).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel sms job: %w", err)
+	}
+	if !cancelled {
+		return nil, fmt.Errorf("sms job is %s, only pending jobs can be cancelled", job.Status)
+	}
+
+	job.Status = models.SmsJobCancelled
+	return job, nil
+}
+
+// ResetSendingSmsJobs 将中断的发送任务恢复为待发送，返回恢复的数量
+func ResetSendingSmsJobs() (int, error) {
+	var count int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		sending := tx.Model(&models.SmsJob{}).Select("sms_id").Where("status = ?", models.SmsJobSending)
+		err := tx.Model(&models.Sms{}).Where("id IN (?)", sending).Update("status", models.SmsStatusQueued).Error
+		if err != nil {
+			return err
+		}
+		result := tx.Model(&models.SmsJob{}).
+			Where("status = ?", models.SmsJobSending).
+			Update("status", models.SmsJobPending)
+		count = int(result.RowsAffected)
+		return result.Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset sms jobs: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,283 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/models"
+	"github.com/rehiy/web-modem/service"
+)
+
+// campaignUploadLimit 上传收件人 CSV 的最大大小
+const campaignUploadLimit = 10 << 20
+
+// CampaignHandler 群发处理器
+type CampaignHandler struct {
+	cs *service.CampaignService
+}
+
+// NewCampaignHandler 创建新的群发处理器
+func NewCampaignHandler() *CampaignHandler {
+	return &CampaignHandler{
+		cs: service.NewCampaignService(),
+	}
+}
+
+// CreateCampaign 创建群发任务，支持 JSON 请求或上传 CSV 文件（multipart/form-data）
+func (h *CampaignHandler) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name          string              `json:"name"`
+		Template      string              `json:"template"`
+		Modems        []string            `json:"modems"`
+		Recipients    []service.Recipient `json:"recipients"`
+		CSV           string              `json:"csv"` // 收件人 CSV 文本，与 recipients 二选一
+		SendAt        *time.Time          `json:"send_at"`
+		LatePolicy    string              `json:"late_policy"`
+		Transliterate bool                `json:"transliterate"`
+		models.SubmitOptions
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(campaignUploadLimit); err != nil {
+			respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+			return
+		}
+		req.Name = r.FormValue("name")
+		req.Template = r.FormValue("template")
+		req.Modems = strings.Split(r.FormValue("modems"), ",")
+		req.LatePolicy = r.FormValue("late_policy")
+		req.Transliterate = r.FormValue("transliterate") == "true"
+		opts, err := parseSubmitForm(r)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+			return
+		}
+		req.SubmitOptions = opts
+		if v := r.FormValue("send_at"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				respondJSON(w, http.StatusBadRequest, H{"error": "invalid send_at"})
+				return
+			}
+			req.SendAt = &t
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, H{"error": "file is required"})
+			return
+		}
+		defer file.Close()
+		if req.Recipients, err = service.ParseRecipientsCSV(file); err != nil {
+			respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+			return
+		}
+	} else {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+			return
+		}
+		if req.CSV != "" {
+			list, err := service.ParseRecipientsCSV(strings.NewReader(req.CSV))
+			if err != nil {
+				respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+				return
+			}
+			req.Recipients = append(req.Recipients, list...)
+		}
+	}
+
+	// 忽略空的设备名称
+	modems := []string{}
+	for _, m := range req.Modems {
+		if m = strings.TrimSpace(m); m != "" {
+			modems = append(modems, m)
+		}
+	}
+
+	opts := service.SendOptions{
+		SendAt:        req.SendAt,
+		LatePolicy:    req.LatePolicy,
+		Transliterate: req.Transliterate,
+		Submit:        req.SubmitOptions,
+	}
+	campaign, err := h.cs.CreateCampaign(req.Name, req.Template, modems, req.Recipients, opts)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, campaign)
+}
+
+// parseSubmitForm 解析表单中的发送选项，字段名称与 JSON 请求一致，pid 支持 0x 开头的十六进制
+func parseSubmitForm(r *http.Request) (models.SubmitOptions, error) {
+	var opts models.SubmitOptions
+	if v := r.FormValue("message_class"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid message_class")
+		}
+		opts.MessageClass = &n
+	}
+	if v := r.FormValue("validity_period"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid validity_period")
+		}
+		opts.ValidityPeriod = n
+	}
+	if v := r.FormValue("valid_until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid valid_until")
+		}
+		opts.ValidUntil = &t
+	}
+	if v := r.FormValue("pid"); v != "" {
+		n, err := strconv.ParseInt(v, 0, 0)
+		if err != nil {
+			return opts, fmt.Errorf("invalid pid")
+		}
+		opts.PID = int(n)
+	}
+	if v := r.FormValue("reject_duplicates"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid reject_duplicates")
+		}
+		opts.RejectDuplicates = b
+	}
+	if v := r.FormValue("status_report"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid status_report")
+		}
+		opts.StatusReport = &b
+	}
+	return opts, nil
+}
+
+// ListCampaigns 获取群发任务列表
+func (h *CampaignHandler) ListCampaigns(w http.ResponseWriter, r *http.Request) {
+	campaigns, err := database.GetCampaignList()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, campaigns)
+}
+
+// GetCampaign 获取群发任务及每个收件人的发送状态
+func (h *CampaignHandler) GetCampaign(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": "invalid id"})
+		return
+	}
+
+	campaign, stats, err := h.cs.GetCampaign(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, H{"error": err.Error()})
+		return
+	}
+
+	recipients, err := database.GetCampaignRecipients(id)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, H{
+		"campaign":   campaign,
+		"stats":      stats,
+		"recipients": recipients,
+	})
+}
+
+// PauseCampaign 暂停群发任务
+func (h *CampaignHandler) PauseCampaign(w http.ResponseWriter, r *http.Request) {
+	h.changeCampaign(w, r, h.cs.PauseCampaign)
+}
+
+// ResumeCampaign 继续群发任务
+func (h *CampaignHandler) ResumeCampaign(w http.ResponseWriter, r *http.Request) {
+	h.changeCampaign(w, r, h.cs.ResumeCampaign)
+}
+
+// CancelCampaign 取消群发任务
+func (h *CampaignHandler) CancelCampaign(w http.ResponseWriter, r *http.Request) {
+	h.changeCampaign(w, r, h.cs.CancelCampaign)
+}
+
+// changeCampaign 解析请求中的群发任务 ID 并修改状态
+func (h *CampaignHandler) changeCampaign(w http.ResponseWriter, r *http.Request, change func(int) (*models.Campaign, error)) {
+	var req struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	campaign, err := change(req.ID)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, campaign)
+}
+
+// DownloadCampaignReport 下载群发任务的发送结果（CSV）
+func (h *CampaignHandler) DownloadCampaignReport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": "invalid id"})
+		return
+	}
+
+	if _, _, err := h.cs.GetCampaign(id); err != nil {
+		respondJSON(w, http.StatusNotFound, H{"error": err.Error()})
+		return
+	}
+	recipients, err := database.GetCampaignRecipients(id)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="campaign-%d.csv"`, id))
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"job_id", "number", "modem_name", "job_status", "sms_status", "error", "sent_at", "delivered_at", "content"})
+	for _, rc := range recipients {
+		cw.Write([]string{
+			strconv.Itoa(rc.JobID),
+			rc.Number,
+			rc.ModemName,
+			rc.JobStatus,
+			rc.SmsStatus,
+			rc.Error,
+			formatTime(rc.SentAt),
+			formatTime(rc.DeliveredAt),
+			rc.Content,
+		})
+	}
+	cw.Flush()
+}
+
+// formatTime 格式化可选时间，为空时返回空字符串
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -2,9 +2,17 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/rehiy/modem/at"
+
+	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/models"
 	"github.com/rehiy/web-modem/service"
 )
 
@@ -20,11 +28,148 @@ func NewModemHandler() *ModemHandler {
 	}
 }
 
-// ListModems 返回可用调制解调器的列表
+// ListModems 返回调制解调器列表，并在后台扫描可用端口，可通过 port 参数指定要扫描的端口，scan=false 时不扫描
 func (h *ModemHandler) ListModems(w http.ResponseWriter, r *http.Request) {
-	h.ms.ScanModems()
-	modems := h.ms.GetConnList()
-	respondJSON(w, http.StatusOK, modems)
+	res := H{}
+	if r.URL.Query().Get("scan") != "false" {
+		job := h.ms.ScanModems(r.URL.Query()["port"]...)
+		res["scan_id"] = job.ID
+	}
+	res["modems"] = h.ms.GetConnList()
+	respondJSON(w, http.StatusOK, res)
+}
+
+// GetScanJob 获取扫描任务进度
+func (h *ModemHandler) GetScanJob(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		respondJSON(w, http.StatusBadRequest, H{"error": "id is required"})
+		return
+	}
+
+	job, err := h.ms.GetScanJob(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, job)
+}
+
+// SetModemAlias 设置调制解调器别名
+func (h *ModemHandler) SetModemAlias(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name  string `json:"name"`
+		Alias string `json:"alias"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	if req.Name == "" {
+		respondJSON(w, http.StatusBadRequest, H{"error": "name is empty"})
+		return
+	}
+
+	conn, err := h.ms.SetAlias(req.Name, req.Alias)
+	if errors.Is(err, database.ErrAliasExists) {
+		respondJSON(w, http.StatusConflict, H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, conn)
+}
+
+// SetModemSmsPolicy 设置设备上短信的删除策略
+func (h *ModemHandler) SetModemSmsPolicy(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name   string `json:"name"`
+		Policy string `json:"policy"`
+		Keep   int    `json:"keep"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	if req.Name == "" {
+		respondJSON(w, http.StatusBadRequest, H{"error": "name is empty"})
+		return
+	}
+
+	conn, err := h.ms.SetSmsPolicy(req.Name, req.Policy, req.Keep)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, conn)
+}
+
+// ConnectModem 连接指定端口，并恢复自动扫描
+func (h *ModemHandler) ConnectModem(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	if req.Name == "" {
+		respondJSON(w, http.StatusBadRequest, H{"error": "name is empty"})
+		return
+	}
+
+	conn, err := h.ms.Connect(req.Name)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, conn)
+}
+
+// DisconnectModem 断开调制解调器并释放端口
+func (h *ModemHandler) DisconnectModem(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	conn, err := h.ms.Disconnect(req.Name)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, conn)
+}
+
+// ForgetModem 断开调制解调器并从设备列表中移除
+func (h *ModemHandler) ForgetModem(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	if err := h.ms.Forget(req.Name); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, H{"status": "forgotten", "name": req.Name})
 }
 
 // SendModemCommand 向调制解调器发送原始 AT 命令
@@ -44,7 +189,11 @@ func (h *ModemHandler) SendModemCommand(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	responses, err := conn.SendCommand(req.Command)
+	var responses []string
+	err = conn.Exec(r.Context(), service.PriorityQuery, req.Command, func() error {
+		responses, err = conn.SendCommand(req.Command)
+		return err
+	})
 	if err != nil {
 		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
 		return
@@ -57,6 +206,53 @@ func (h *ModemHandler) SendModemCommand(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// GetModemQueue 获取调制解调器命令队列状态
+func (h *ModemHandler) GetModemQueue(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		respondJSON(w, http.StatusBadRequest, H{"error": "name is empty"})
+		return
+	}
+
+	stats, err := h.ms.GetQueue(name)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, stats)
+}
+
+// ListCaptures 获取抓包文件列表，可通过 name 参数指定设备
+func (h *ModemHandler) ListCaptures(w http.ResponseWriter, r *http.Request) {
+	files, err := h.ms.ListCaptures(r.URL.Query().Get("name"))
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, files)
+}
+
+// DownloadCapture 下载抓包文件
+func (h *ModemHandler) DownloadCapture(w http.ResponseWriter, r *http.Request) {
+	file := r.URL.Query().Get("file")
+	if file == "" {
+		respondJSON(w, http.StatusBadRequest, H{"error": "file is empty"})
+		return
+	}
+
+	p, err := service.CapturePath(file)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, H{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file))
+	http.ServeFile(w, r, p)
+}
+
 // GetModemBasicInfo 获取调制解调器基本信息
 func (h *ModemHandler) GetModemBasicInfo(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Query().Get("name")
@@ -71,43 +267,99 @@ func (h *ModemHandler) GetModemBasicInfo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	info := H{"name": name}
-	// 获取制造商
-	if manufacturer, err := conn.GetManufacturer(); err == nil {
-		info["manufacturer"] = manufacturer
+	// 每条查询单独排队，避免阻塞短信处理
+	query := func(cmd string, fn func() error) {
+		conn.Exec(r.Context(), service.PriorityQuery, cmd, fn)
 	}
+
+	info := H{"name": conn.Name, "alias": conn.Alias, "port": conn.Port, "profile": conn.Profile}
+	// 获取制造商
+	query("AT+CGMI", func() error {
+		manufacturer, err := conn.GetManufacturer()
+		if err == nil {
+			info["manufacturer"] = manufacturer
+		}
+		return err
+	})
 	// 获取型号
-	if model, err := conn.GetModel(); err == nil {
-		info["model"] = model
-	}
+	query("AT+CGMM", func() error {
+		model, err := conn.GetModel()
+		if err == nil {
+			info["model"] = model
+		}
+		return err
+	})
 	// 获取IMEI/序列号
-	if imei, err := conn.GetIMEI(); err == nil {
-		info["imei"] = imei
+	query("AT+CGSN", func() error {
+		imei, err := conn.GetIMEI()
+		if err == nil {
+			info["imei"] = imei
+		}
+		return err
+	})
+	// 获取USB设备信息
+	if conn.USB != nil {
+		info["usb"] = conn.USB
 	}
-	// 获取IMSI
-	if imsi, err := conn.GetIMSI(); err == nil {
-		info["imsi"] = imsi
+	// 获取ICCID
+	if conn.ICCID != "" {
+		info["iccid"] = conn.ICCID
 	}
+	// 获取IMSI
+	query("AT+CIMI", func() error {
+		imsi, err := conn.GetIMSI()
+		if err == nil {
+			info["imsi"] = imsi
+		}
+		return err
+	})
 	// 获取手机号
-	if number, _, err := conn.GetNumber(); err == nil {
-		info["number"] = number
-	}
+	query("AT+CNUM", func() error {
+		number, _, err := conn.GetNumber()
+		if err == nil {
+			info["number"] = number
+		}
+		return err
+	})
 	// 获取运营商（当前注册网络/Visited PLMN）
-	if _, _, operator, act, err := conn.GetOperator(); err == nil {
-		info["operator"] = operator
-		info["act"] = act
-	}
+	query("AT+COPS?", func() error {
+		_, _, operator, act, err := conn.GetOperator()
+		if err == nil {
+			info["operator"] = operator
+			info["act"] = act
+		}
+		return err
+	})
 	// 获取短信中心
-	if center, _, err := conn.GetSmsCenter(); err == nil {
-		info["sms_center"] = center
-	}
+	query("AT+CSCA?", func() error {
+		center, _, err := conn.GetSmsCenter()
+		if err == nil {
+			info["sms_center"] = center
+		}
+		return err
+	})
 	// 获取短信模式
-	if mode, err := conn.GetSmsMode(); err == nil {
-		info["sms_mode"] = "text"
-		if mode == 0 {
-			info["sms_mode"] = "pdu"
+	query("AT+CMGF?", func() error {
+		mode, err := conn.GetSmsMode()
+		if err == nil {
+			info["sms_mode"] = "text"
+			if mode == 0 {
+				info["sms_mode"] = "pdu"
+			}
 		}
+		return err
+	})
+	// 获取短信存储使用情况，查询失败时使用定期刷新的结果
+	if storage, err := h.ms.GetStorage(name); err == nil {
+		info["storage"] = storage
 	}
+	query("AT+CPMS?", func() error {
+		storage, err := conn.GetSmsStorage()
+		if err == nil {
+			info["storage"] = storage
+		}
+		return err
+	})
 
 	respondJSON(w, http.StatusOK, info)
 }
@@ -126,7 +378,11 @@ func (h *ModemHandler) GetModemSignal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rssi, ber, err := conn.GetSignalQuality()
+	var rssi, ber int
+	err = conn.Exec(r.Context(), service.PriorityQuery, "AT+CSQ", func() error {
+		rssi, ber, err = conn.GetSignalQuality()
+		return err
+	})
 	if err != nil {
 		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
 		return
@@ -146,29 +402,151 @@ func (h *ModemHandler) GetModemSignal(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// SendModemSms 发送短信
+// SendModemSms 将短信加入发送队列，并记录到数据库，send_at 为 RFC3339 格式的定时发送时间
 func (h *ModemHandler) SendModemSms(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Name    string `json:"name"`
-		Number  string `json:"number"`
-		Message string `json:"message"`
+		Name          string     `json:"name"`
+		Number        string     `json:"number"`
+		Message       string     `json:"message"`
+		SendAt        *time.Time `json:"send_at"`
+		LatePolicy    string     `json:"late_policy"`
+		Transliterate bool       `json:"transliterate"`
+		models.SubmitOptions
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
 		return
 	}
 
-	conn, err := h.ms.GetConn(req.Name)
-	if conn == nil {
+	opts := service.SendOptions{
+		SendAt:        req.SendAt,
+		LatePolicy:    req.LatePolicy,
+		Transliterate: req.Transliterate,
+		Submit:        req.SubmitOptions,
+	}
+	job, err := h.ms.EnqueueSms(req.Name, req.Number, req.Message, opts)
+	if err != nil {
 		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
 		return
 	}
 
-	if err := conn.SendSmsPdu(req.Number, req.Message); err != nil {
+	respondJSON(w, http.StatusAccepted, H{"status": "queued", "job_id": job.ID, "job": job})
+}
+
+// PreviewModemSms 预览短信的编码方式、分段数量及无法用 GSM-7 编码的字符
+func (h *ModemHandler) PreviewModemSms(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Message       string `json:"message"`
+		Transliterate bool   `json:"transliterate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	preview, err := service.PreviewSms(req.Message, req.Transliterate)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, preview)
+}
+
+// GetSmsJob 获取短信任务状态
+func (h *ModemHandler) GetSmsJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": "invalid id"})
+		return
+	}
+
+	job, err := database.GetSmsJob(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, job)
+}
+
+// ListSmsJobs 获取短信任务列表，可按设备和状态过滤，scheduled=true 时只返回定时任务
+func (h *ModemHandler) ListSmsJobs(w http.ResponseWriter, r *http.Request) {
+	filter := &models.SmsJobFilter{
+		Status:    r.URL.Query().Get("status"),
+		Scheduled: r.URL.Query().Get("scheduled") == "true",
+		Limit:     50,
+	}
+	if name := r.URL.Query().Get("name"); name != "" {
+		filter.ModemName = h.ms.ResolveName(name)
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil && l > 0 && l <= 200 {
+			filter.Limit = l
+		}
+	}
+	if offset := r.URL.Query().Get("offset"); offset != "" {
+		if o, err := strconv.Atoi(offset); err == nil && o >= 0 {
+			filter.Offset = o
+		}
+	}
+
+	jobs, total, err := database.GetSmsJobList(filter)
+	if err != nil {
 		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
-	} else {
-		respondJSON(w, http.StatusOK, H{"status": "sent"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, H{
+		"data":   jobs,
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// RescheduleSmsJob 修改等待中的短信任务的定时发送时间
+func (h *ModemHandler) RescheduleSmsJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID         int       `json:"id"`
+		SendAt     time.Time `json:"send_at"`
+		LatePolicy string    `json:"late_policy"`
 	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+	if req.SendAt.IsZero() {
+		respondJSON(w, http.StatusBadRequest, H{"error": "send_at is empty"})
+		return
+	}
+
+	job, err := h.ms.RescheduleSmsJob(req.ID, req.SendAt, req.LatePolicy)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, H{"status": "rescheduled", "job": job})
+}
+
+// CancelSmsJob 取消等待中的短信任务
+func (h *ModemHandler) CancelSmsJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.ms.CancelSmsJob(req.ID)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, H{"status": "cancelled", "job": job})
 }
 
 // ListModemSms 获取调制解调器中的所有短信
@@ -185,7 +563,11 @@ func (h *ModemHandler) ListModemSms(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	smsList, err := conn.ListSmsPdu(4)
+	var smsList []at.Sms
+	err = conn.Exec(r.Context(), service.PriorityQuery, "AT+CMGL", func() error {
+		smsList, err = conn.ListSmsPdu(4)
+		return err
+	})
 	if err != nil {
 		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
 		return
@@ -211,9 +593,63 @@ func (h *ModemHandler) DeleteModemSms(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := conn.DeleteSms(req.Indices); err != nil {
+	err = conn.Exec(r.Context(), service.PriorityQuery, "AT+CMGD", func() error {
+		return conn.DeleteSms(req.Indices)
+	})
+	if err != nil {
 		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
 	} else {
 		respondJSON(w, http.StatusOK, H{"status": "deleted", "count": len(req.Indices)})
 	}
 }
+
+// ListPortSettings 获取所有端口串口参数
+func (h *ModemHandler) ListPortSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := database.GetPortSettingList()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// UpdatePortSetting 更新端口串口参数，下次连接时生效
+func (h *ModemHandler) UpdatePortSetting(w http.ResponseWriter, r *http.Request) {
+	var setting models.PortSetting
+	if err := json.NewDecoder(r.Body).Decode(&setting); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	if err := service.CheckPortSetting(&setting); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	if err := database.SavePortSetting(&setting); err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, setting)
+}
+
+// DeletePortSetting 删除端口串口参数
+func (h *ModemHandler) DeletePortSetting(w http.ResponseWriter, r *http.Request) {
+	port := r.URL.Query().Get("port")
+	if port == "" {
+		respondJSON(w, http.StatusBadRequest, H{"error": "port is required"})
+		return
+	}
+
+	if err := database.DeletePortSetting(port); err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, H{
+		"status": "deleted",
+		"port":   port,
+	})
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/models"
+	"github.com/rehiy/web-modem/service"
+)
+
+// ProfileHandler 设备配置档案处理器
+type ProfileHandler struct{}
+
+// NewProfileHandler 创建新的设备配置档案处理器
+func NewProfileHandler() *ProfileHandler {
+	return &ProfileHandler{}
+}
+
+// ListProfiles 获取所有设备配置档案，包括内置档案
+func (h *ProfileHandler) ListProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles, err := service.ListProfiles()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, profiles)
+}
+
+// CreateProfile 创建设备配置档案
+func (h *ProfileHandler) CreateProfile(w http.ResponseWriter, r *http.Request) {
+	var profile models.ModemProfile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	if err := service.CheckProfile(&profile); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	if err := database.CreateProfile(&profile); err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, profile)
+}
+
+// UpdateProfile 更新设备配置档案，下次连接时生效
+func (h *ProfileHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": "invalid id"})
+		return
+	}
+
+	var profile models.ModemProfile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	profile.ID = id
+
+	if err := service.CheckProfile(&profile); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	if err := database.UpdateProfile(&profile); err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, profile)
+}
+
+// DeleteProfile 删除设备配置档案
+func (h *ProfileHandler) DeleteProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": "invalid id"})
+		return
+	}
+
+	if err := database.DeleteProfile(id); err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, H{
+		"status": "deleted",
+		"id":     id,
+	})
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/models"
+)
+
+// RouteHandler 短信路由规则处理器
+type RouteHandler struct{}
+
+// NewRouteHandler 创建新的短信路由规则处理器
+func NewRouteHandler() *RouteHandler {
+	return &RouteHandler{}
+}
+
+// CreateRoute 创建短信路由规则
+func (h *RouteHandler) CreateRoute(w http.ResponseWriter, r *http.Request) {
+	route := models.SmsRoute{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&route); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	if route.ModemName == "" && route.Operator == "" {
+		respondJSON(w, http.StatusBadRequest, H{"error": "modem_name or operator is required"})
+		return
+	}
+
+	if err := database.CreateSmsRoute(&route); err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, route)
+}
+
+// ListRoutes 获取所有短信路由规则
+func (h *RouteHandler) ListRoutes(w http.ResponseWriter, r *http.Request) {
+	routes, err := database.GetSmsRouteList()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, routes)
+}
+
+// UpdateRoute 更新短信路由规则
+func (h *RouteHandler) UpdateRoute(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": "invalid id"})
+		return
+	}
+
+	route, err := database.GetSmsRoute(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, H{"error": err.Error()})
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(route); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+	route.ID = id
+
+	if route.ModemName == "" && route.Operator == "" {
+		respondJSON(w, http.StatusBadRequest, H{"error": "modem_name or operator is required"})
+		return
+	}
+
+	if err := database.UpdateSmsRoute(route); err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, route)
+}
+
+// DeleteRoute 删除短信路由规则
+func (h *RouteHandler) DeleteRoute(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": "invalid id"})
+		return
+	}
+
+	if err := database.DeleteSmsRoute(id); err != nil {
+		respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, H{
+		"status": "deleted",
+		"id":     id,
+	})
+}
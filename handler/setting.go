@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/service"
 )
 
 // SettingHandler 设置处理器
@@ -69,3 +70,130 @@ func (h *SettingHandler) UpdateWebhookSettings(w http.ResponseWriter, r *http.Re
 		"webhook_enabled": req.WebhookEnabled,
 	})
 }
+
+// UpdateSenderSettings 更新短信发送设置，未提供的字段保持不变
+func (h *SettingHandler) UpdateSenderSettings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SmsRateLimit *int    `json:"sms_rate_limit"`
+		StatusReport *bool   `json:"status_report"`
+		RoutePolicy  *string `json:"route_policy"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	if req.SmsRateLimit != nil && *req.SmsRateLimit < 0 {
+		respondJSON(w, http.StatusBadRequest, H{"error": "sms_rate_limit must not be negative"})
+		return
+	}
+
+	if req.RoutePolicy != nil && !service.IsRoutePolicy(*req.RoutePolicy) {
+		respondJSON(w, http.StatusBadRequest, H{"error": "invalid route_policy"})
+		return
+	}
+
+	if req.SmsRateLimit != nil {
+		if err := database.SetSmsRateLimit(*req.SmsRateLimit); err != nil {
+			respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.StatusReport != nil {
+		if err := database.SetStatusReportEnabled(*req.StatusReport); err != nil {
+			respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.RoutePolicy != nil {
+		if err := database.SetSmsRoutePolicy(*req.RoutePolicy); err != nil {
+			respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+			return
+		}
+	}
+
+	respondJSON(w, http.StatusOK, H{
+		"status":         "updated",
+		"sms_rate_limit": database.GetSmsRateLimit(),
+		"status_report":  database.IsStatusReportEnabled(),
+		"route_policy":   database.GetSmsRoutePolicy(),
+	})
+}
+
+// UpdateReceiverSettings 更新短信接收设置，未提供的字段保持不变
+func (h *SettingHandler) UpdateReceiverSettings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IngestStored   *bool   `json:"ingest_stored"`
+		IngestInterval *int    `json:"ingest_interval"`
+		DeleteAfter    *string `json:"sms_delete_after"`
+		WarnPercent    *int    `json:"storage_warn_percent"`
+		StoragePurge   *bool   `json:"storage_purge"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	if req.IngestInterval != nil && *req.IngestInterval < 0 {
+		respondJSON(w, http.StatusBadRequest, H{"error": "ingest_interval must not be negative"})
+		return
+	}
+
+	if req.DeleteAfter != nil && !service.IsDeleteAfter(*req.DeleteAfter) {
+		respondJSON(w, http.StatusBadRequest, H{"error": "invalid sms_delete_after"})
+		return
+	}
+
+	if req.WarnPercent != nil && (*req.WarnPercent < 0 || *req.WarnPercent > 100) {
+		respondJSON(w, http.StatusBadRequest, H{"error": "storage_warn_percent must be between 0 and 100"})
+		return
+	}
+
+	if req.IngestStored != nil {
+		if err := database.SetIngestStoredEnabled(*req.IngestStored); err != nil {
+			respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.IngestInterval != nil {
+		if err := database.SetIngestInterval(*req.IngestInterval); err != nil {
+			respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.DeleteAfter != nil {
+		if err := database.SetSmsDeleteAfter(*req.DeleteAfter); err != nil {
+			respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.WarnPercent != nil {
+		if err := database.SetStorageWarnPercent(*req.WarnPercent); err != nil {
+			respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.StoragePurge != nil {
+		if err := database.SetStoragePurgeEnabled(*req.StoragePurge); err != nil {
+			respondJSON(w, http.StatusInternalServerError, H{"error": err.Error()})
+			return
+		}
+	}
+
+	respondJSON(w, http.StatusOK, H{
+		"status":               "updated",
+		"ingest_stored":        database.IsIngestStoredEnabled(),
+		"ingest_interval":      database.GetIngestInterval(),
+		"sms_delete_after":     database.GetSmsDeleteAfter(),
+		"storage_warn_percent": database.GetStorageWarnPercent(),
+		"storage_purge":        database.IsStoragePurgeEnabled(),
+	})
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rehiy/web-modem/simulator"
+)
+
+// SimulatorHandler 虚拟调制解调器控制处理器
+type SimulatorHandler struct{}
+
+// NewSimulatorHandler 创建新的虚拟调制解调器控制处理器
+func NewSimulatorHandler() *SimulatorHandler {
+	return &SimulatorHandler{}
+}
+
+// ListSimulators 获取所有虚拟调制解调器的状态
+func (h *SimulatorHandler) ListSimulators(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, simulator.List())
+}
+
+// InjectSms 模拟虚拟调制解调器收到短信
+func (h *SimulatorHandler) InjectSms(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name     string `json:"name"`
+		From     string `json:"from"`
+		Text     string `json:"text"`
+		Interval int    `json:"interval"` // 长短信各分段的到达间隔（秒）
+		Drop     []int  `json:"drop"`     // 不会到达的分段序号
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	m, err := simulator.Find(req.Name)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, H{"error": err.Error()})
+		return
+	}
+
+	if err := m.InjectSmsParts(req.From, req.Text, time.Duration(req.Interval)*time.Second, req.Drop); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, H{"status": "injected"})
+}
+
+// SetSignal 设置虚拟调制解调器的信号强度
+func (h *SimulatorHandler) SetSignal(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+		RSSI int    `json:"rssi"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	m, err := simulator.Find(req.Name)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, H{"error": err.Error()})
+		return
+	}
+
+	if err := m.SetSignal(req.RSSI); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, m.Status())
+}
+
+// SetRegistration 设置虚拟调制解调器的网络注册状态
+func (h *SimulatorHandler) SetRegistration(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name     string `json:"name"`
+		Stat     int    `json:"stat"`
+		Operator string `json:"operator"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	m, err := simulator.Find(req.Name)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, H{"error": err.Error()})
+		return
+	}
+
+	if err := m.SetRegistration(req.Stat, req.Operator); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, m.Status())
+}
+
+// SetService 设置虚拟调制解调器的消息服务（AT+CSMS），服务重新连接后按新的设置确认直接上报
+func (h *SimulatorHandler) SetService(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name    string `json:"name"`
+		Service int    `json:"service"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	m, err := simulator.Find(req.Name)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, H{"error": err.Error()})
+		return
+	}
+
+	if err := m.SetService(req.Service); err != nil {
+		respondJSON(w, http.StatusBadRequest, H{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, m.Status())
+}
@@ -36,8 +36,12 @@ func (h *SmsdbHandler) ListSms(w http.ResponseWriter, r *http.Request) {
 		filter.SendNumber = sendNumber
 	}
 
+	if status := r.URL.Query().Get("status"); status != "" {
+		filter.Status = status
+	}
+
 	if modemName := r.URL.Query().Get("modem_name"); modemName != "" {
-		filter.ModemName = modemName
+		filter.ModemName = service.GetModemService().ResolveName(modemName)
 	}
 
 	if startTime := r.URL.Query().Get("start_time"); startTime != "" {
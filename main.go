@@ -6,13 +6,16 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/rehiy/web-modem/database"
 	"github.com/rehiy/web-modem/router"
+	"github.com/rehiy/web-modem/service"
 )
 
 const (
-	listenPort = "8080"
+	listenPort    = "8080"
+	watchInterval = 3 * time.Second
 )
 
 func main() {
@@ -27,6 +30,22 @@ func main() {
 	}
 	defer database.Close()
 
+	// 恢复中断的短信发送任务
+	service.GetModemService().RecoverSmsJobs()
+
+	// 监听设备插拔
+	interval := watchInterval
+	if v := os.Getenv("MODEM_WATCH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid MODEM_WATCH_INTERVAL: %v", err)
+		}
+		interval = d
+	}
+	if interval > 0 {
+		go service.GetModemService().WatchModems(interval)
+	}
+
 	// 启动服务器
 	go func() {
 		log.Printf("Server starting on :%s", port)
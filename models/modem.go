@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+)
+
+// Modem 设备身份模型，以 IMEI 作为稳定标识
+type Modem struct {
+	IMEI      string    `json:"imei" gorm:"primaryKey;type:text"`
+	ICCID     string    `json:"iccid" gorm:"type:text;index:idx_modem_iccid"`
+	Alias     string    `json:"alias" gorm:"type:text;index:idx_modem_alias"`
+	Port      string    `json:"port" gorm:"type:text"`
+	SmsDelete string    `json:"sms_delete" gorm:"type:text"` // 设备上短信的删除策略，见 SmsDelete*，为空时处理后删除
+	SmsKeep   int       `json:"sms_keep"`                    // keep_recent 策略保留的短信数量
+	LastSeen  time.Time `json:"last_seen"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// 设备上短信的删除策略
+const (
+	SmsDeleteAll    = "delete"      // 处理后删除
+	SmsDeleteKeep   = "keep"        // 保留在设备上
+	SmsDeleteRecent = "keep_recent" // 只保留最近的若干条
+)
+
+// 删除设备上短信的时机
+const (
+	DeleteAfterStored  = "stored"  // 保存到数据库后，未启用短信存储时同 webhook
+	DeleteAfterWebhook = "webhook" // 所有 Webhook 成功后
+)
+
+// PortSetting 端口参数，零值表示使用默认值
+type PortSetting struct {
+	Port           string    `json:"port" gorm:"primaryKey;type:text"`
+	Baud           int       `json:"baud"`
+	DataBits       int       `json:"data_bits"`
+	Parity         string    `json:"parity" gorm:"type:text"` // N/O/E/M/S
+	StopBits       int       `json:"stop_bits"`               // 1, 2 或 15（1.5）
+	RTSCTS         *bool     `json:"rtscts"`                  // 为空时使用 MODEM_PORT 中的设置
+	ReadTimeout    int       `json:"read_timeout"`            // 毫秒
+	CommandTimeout int       `json:"command_timeout"`         // 毫秒
+	Profile        string    `json:"profile" gorm:"type:text"`
+	SmsReceive     string    `json:"sms_receive" gorm:"type:text"` // 短信接收方式：store 或 direct
+	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// ModemProfile 设备配置档案，按厂商和型号匹配初始化参数
+type ModemProfile struct {
+	ID           int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name         string    `json:"name" gorm:"not null;unique;type:text"`
+	Manufacturer string    `json:"manufacturer" gorm:"type:text"` // 匹配 AT+CGMI 的正则表达式
+	Model        string    `json:"model" gorm:"type:text"`        // 匹配 AT+CGMM 的正则表达式
+	InitScript   string    `json:"init_script" gorm:"type:text"`  // 初始化 AT 命令，每行一条
+	SmsStore     string    `json:"sms_store" gorm:"type:text"`    // 短信存储，如 ME 或 SM,SM,ME
+	Cnmi         string    `json:"cnmi" gorm:"type:text"`         // AT+CNMI 参数，如 2,1,0,0,0
+	Quirks       string    `json:"quirks" gorm:"type:text"`       // 兼容性选项，逗号分隔
+	Builtin      bool      `json:"builtin" gorm:"-"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
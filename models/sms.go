@@ -14,7 +14,153 @@ type Sms struct {
 	SendNumber    string    `json:"send_number" gorm:"type:text;index:idx_sms_send_number"`
 	Direction     string    `json:"direction" gorm:"not null;type:text;check:direction IN ('in', 'out');index:idx_sms_direction"` // "in" 或 "out"
 	ModemName     string    `json:"modem_name" gorm:"type:text;index:idx_sms_modem_name"`
+	ICCID         string    `json:"iccid" gorm:"type:text"`
+	Partial       bool      `json:"partial"` // 长短信等待超时，缺少部分分段
 	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+
+	// 以下字段仅用于发送的短信
+	Status      string     `json:"status" gorm:"type:text;index:idx_sms_status"` // 发送状态，见 SmsStatus*
+	MessageRefs string     `json:"message_refs" gorm:"type:text"`                // +CMGS 返回的消息参考号，逗号分隔
+	Segments    int        `json:"segments"`                                     // 分段数量
+	Reported    string     `json:"reported" gorm:"type:text"`                    // 已收到投递成功状态报告的消息参考号，逗号分隔
+	Error       string     `json:"error" gorm:"type:text"`
+	SentAt      *time.Time `json:"sent_at"`
+	DeliveredAt *time.Time `json:"delivered_at"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// ReceivedPdu 已处理的收到短信分段，避免重复处理仍在设备上的短信
+type ReceivedPdu struct {
+	ModemName   string    `json:"modem_name" gorm:"primaryKey;type:text"`
+	Fingerprint string    `json:"fingerprint" gorm:"primaryKey;type:text"` // TPDU 的 SHA-256
+	SmsID       int       `json:"sms_id"`                                  // 已保存的短信记录，0 表示未保存
+	Hooked      string    `json:"hooked" gorm:"type:text"`                 // 已成功触发的 Webhook ID，逗号分隔，重试时跳过
+	Done        bool      `json:"done"`                                    // 已完成处理，未完成时下次读取时重试
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// 发送短信的状态
+const (
+	SmsStatusQueued        = "queued"        // 等待发送
+	SmsStatusSending       = "sending"       // 正在发送
+	SmsStatusSent          = "sent"          // 已提交到短信中心
+	SmsStatusFailed        = "failed"        // 发送失败
+	SmsStatusDelivered     = "delivered"     // 已送达
+	SmsStatusUndeliverable = "undeliverable" // 无法送达
+	SmsStatusCancelled     = "cancelled"     // 已取消
+	SmsStatusExpired       = "expired"       // 错过定时发送时间或超过有效期，未发送
+)
+
+// SmsJob 发送队列中的短信任务
+type SmsJob struct {
+	ID         int        `json:"id" gorm:"primaryKey;autoIncrement"`
+	SmsID      int        `json:"sms_id" gorm:"index:idx_sms_job_sms_id"`           // 对应的发送记录
+	CampaignID int        `json:"campaign_id" gorm:"index:idx_sms_job_campaign_id"` // 所属的群发任务，0 表示单独发送
+	ModemName  string     `json:"modem_name" gorm:"not null;type:text;index:idx_sms_job_modem_name"`
+	Number     string     `json:"number" gorm:"not null;type:text"`
+	Content    string     `json:"content" gorm:"not null;type:text"`
+	Status     string     `json:"status" gorm:"not null;type:text;index:idx_sms_job_status"` // 见 SmsJob*
+	Attempts   int        `json:"attempts"`
+	LastError  string     `json:"last_error" gorm:"type:text"`
+	NextRunAt  time.Time  `json:"next_run_at" gorm:"index:idx_sms_job_next_run_at"` // 最早执行时间，重试时延后
+	SendAt     *time.Time `json:"send_at"`                                          // 定时发送时间，为空时立即发送
+	LatePolicy string     `json:"late_policy" gorm:"type:text"`                     // 错过定时发送时间的处理方式，见 LatePolicy*
+	AutoRoute  bool       `json:"auto_route"`                                       // 由路由策略选择设备，发送失败时切换到其他设备
+	Tried      string     `json:"tried" gorm:"type:text"`                           // 本轮已发送失败的设备，逗号分隔
+	Submitted  string     `json:"submitted" gorm:"type:text"`                       // 已提交的分段的消息参考号，逗号分隔，重试时从下一个分段继续
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+
+	SubmitOptions `gorm:"embedded"`
+}
+
+// SubmitOptions SMS-SUBMIT 的可选参数（3GPP TS 23.040 9.2.2.2）
+type SubmitOptions struct {
+	MessageClass     *int       `json:"message_class,omitempty"`     // 消息类别 0-3，0 为闪信，为空时不指定
+	ValidityPeriod   int        `json:"validity_period,omitempty"`   // 相对有效期（秒），短信中心按 5 分钟到 63 周的档位取整
+	ValidUntil       *time.Time `json:"valid_until,omitempty"`       // 绝对有效期，与 validity_period 二选一
+	PID              int        `json:"pid,omitempty"`               // TP-PID，0x41-0x47 为替换短信类型 1-7
+	RejectDuplicates bool       `json:"reject_duplicates,omitempty"` // 短信中心拒绝重复的短信（TP-RD）
+	StatusReport     *bool      `json:"status_report,omitempty"`     // 是否请求状态报告，为空时使用 status_report 设置
+}
+
+// 短信任务的状态
+const (
+	SmsJobPending   = "pending"   // 等待发送或重试
+	SmsJobSending   = "sending"   // 正在发送
+	SmsJobSent      = "sent"      // 已发送
+	SmsJobFailed    = "failed"    // 发送失败，不再重试
+	SmsJobCancelled = "cancelled" // 已取消
+	SmsJobExpired   = "expired"   // 错过定时发送时间或超过有效期，不再发送
+	SmsJobPaused    = "paused"    // 群发任务已暂停
+)
+
+// 错过定时发送时间的处理方式
+const (
+	LatePolicySend   = "send"   // 延迟发送
+	LatePolicyExpire = "expire" // 标记为过期
+)
+
+// SmsRoute 短信路由规则，未指定设备时按收件人号码前缀限定可用的设备
+type SmsRoute struct {
+	ID        int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Prefix    string    `json:"prefix" gorm:"type:text"`     // 收件人号码前缀，为空时匹配所有号码，填写完整号码可将收件人固定到设备
+	ModemName string    `json:"modem_name" gorm:"type:text"` // 使用的设备，可以是 IMEI 或别名
+	Operator  string    `json:"operator" gorm:"type:text"`   // 使用注册到该运营商的设备，匹配运营商名称或 PLMN
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// 未指定设备时选择设备的策略
+const (
+	RoutePolicyRoundRobin  = "round_robin"  // 轮流使用
+	RoutePolicyLeastLoaded = "least_loaded" // 待发送任务最少
+	RoutePolicyBestSignal  = "best_signal"  // 信号最强
+	RoutePolicySticky      = "sticky"       // 优先使用上次发送给该号码的设备
+)
+
+// SmsJobFilter 短信任务查询过滤器
+type SmsJobFilter struct {
+	ModemName string `json:"modem_name,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Scheduled bool   `json:"scheduled,omitempty"` // 只查询定时发送的任务
+	Limit     int    `json:"limit,omitempty"`
+	Offset    int    `json:"offset,omitempty"`
+}
+
+// Campaign 群发任务，按模板为每个收件人生成短信
+type Campaign struct {
+	ID        int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name      string    `json:"name" gorm:"not null;type:text"`
+	Template  string    `json:"template" gorm:"not null;type:text"` // 短信模板，{{变量}} 替换为收件人的变量
+	Modems    string    `json:"modems" gorm:"type:text"`            // 发送使用的设备，逗号分隔，按顺序轮流分配，未指定设备时为路由选择的设备
+	Status    string    `json:"status" gorm:"not null;type:text"`   // 见 Campaign*
+	Total     int       `json:"total"`                              // 收件人数量
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// 群发任务的状态
+const (
+	CampaignRunning   = "running"   // 发送中
+	CampaignPaused    = "paused"    // 已暂停
+	CampaignCancelled = "cancelled" // 已取消
+	CampaignCompleted = "completed" // 所有短信已处理
+)
+
+// CampaignRecipient 群发任务中单个收件人的发送结果
+type CampaignRecipient struct {
+	JobID       int        `json:"job_id"`
+	SmsID       int        `json:"sms_id"`
+	ModemName   string     `json:"modem_name"`
+	Number      string     `json:"number"`
+	Content     string     `json:"content"`
+	JobStatus   string     `json:"job_status"`
+	SmsStatus   string     `json:"sms_status"`
+	Error       string     `json:"error"`
+	SentAt      *time.Time `json:"sent_at"`
+	DeliveredAt *time.Time `json:"delivered_at"`
 }
 
 // SmsFilter 短信查询过滤器
@@ -22,6 +168,7 @@ type SmsFilter struct {
 	Direction  string    `json:"direction,omitempty"`
 	SendNumber string    `json:"send_number,omitempty"`
 	ModemName  string    `json:"modem_name,omitempty"`
+	Status     string    `json:"status,omitempty"`
 	StartTime  time.Time `json:"start_time,omitempty"`
 	EndTime    time.Time `json:"end_time,omitempty"`
 	Limit      int       `json:"limit,omitempty"`
@@ -34,6 +181,7 @@ type Webhook struct {
 	Name      string    `json:"name" gorm:"not null;unique;type:text"`
 	URL       string    `json:"url" gorm:"not null;type:text"`
 	Template  string    `json:"template" gorm:"type:text;default:'{}'"`
+	Events    string    `json:"events" gorm:"type:text"` // 订阅的事件，逗号分隔，为空时只订阅 sms_received
 	Enabled   bool      `json:"enabled" gorm:"default:true"`
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
@@ -51,4 +199,6 @@ type Setting struct {
 type Settings struct {
 	SmsdbEnabled   bool `json:"smsdb_enabled"`
 	WebhookEnabled bool `json:"webhook_enabled"`
+	SmsRateLimit   int  `json:"sms_rate_limit"`
+	StatusReport   bool `json:"status_report"`
 }
@@ -16,9 +16,13 @@ func Apply() http.Handler {
 	// API 路由
 	api := r.PathPrefix("/api").Subrouter()
 	ModemRegister(api)
+	ProfileRegister(api)
 	SmsdbRegister(api)
 	WebhookRegister(api)
+	CampaignRegister(api)
+	RouteRegister(api)
 	SettingRegister(api)
+	SimulatorRegister(api)
 
 	// WebSocket
 	WebSocketRegister(r)
@@ -35,16 +39,49 @@ func ModemRegister(r *mux.Router) {
 
 	// 模块列表
 	r.HandleFunc("/modem/list", mh.ListModems).Methods("GET")
+	r.HandleFunc("/modem/scan", mh.GetScanJob).Methods("GET")
+	r.HandleFunc("/modem/alias", mh.SetModemAlias).Methods("POST")
+	r.HandleFunc("/modem/connect", mh.ConnectModem).Methods("POST")
+	r.HandleFunc("/modem/disconnect", mh.DisconnectModem).Methods("POST")
+	r.HandleFunc("/modem/forget", mh.ForgetModem).Methods("POST")
+
+	// 端口参数
+	r.HandleFunc("/modem/port/settings", mh.ListPortSettings).Methods("GET")
+	r.HandleFunc("/modem/port/settings", mh.UpdatePortSetting).Methods("PUT")
+	r.HandleFunc("/modem/port/settings", mh.DeletePortSetting).Methods("DELETE")
 
 	// 模块操作
 	r.HandleFunc("/modem/send", mh.SendModemCommand).Methods("POST")
 	r.HandleFunc("/modem/info", mh.GetModemBasicInfo).Methods("GET")
 	r.HandleFunc("/modem/signal", mh.GetModemSignal).Methods("GET")
+	r.HandleFunc("/modem/queue", mh.GetModemQueue).Methods("GET")
+
+	// 抓包文件
+	r.HandleFunc("/modem/capture/list", mh.ListCaptures).Methods("GET")
+	r.HandleFunc("/modem/capture/download", mh.DownloadCapture).Methods("GET")
 
 	// 短信读写
 	r.HandleFunc("/modem/sms/list", mh.ListModemSms).Methods("GET")
 	r.HandleFunc("/modem/sms/send", mh.SendModemSms).Methods("POST")
+	r.HandleFunc("/modem/sms/preview", mh.PreviewModemSms).Methods("POST")
 	r.HandleFunc("/modem/sms/delete", mh.DeleteModemSms).Methods("POST")
+	r.HandleFunc("/modem/sms/policy", mh.SetModemSmsPolicy).Methods("POST")
+
+	// 发送队列
+	r.HandleFunc("/modem/sms/job", mh.GetSmsJob).Methods("GET")
+	r.HandleFunc("/modem/sms/jobs", mh.ListSmsJobs).Methods("GET")
+	r.HandleFunc("/modem/sms/reschedule", mh.RescheduleSmsJob).Methods("POST")
+	r.HandleFunc("/modem/sms/cancel", mh.CancelSmsJob).Methods("POST")
+}
+
+func ProfileRegister(r *mux.Router) {
+	ph := handler.NewProfileHandler()
+
+	// 设备配置档案
+	r.HandleFunc("/modem/profile/list", ph.ListProfiles).Methods("GET")
+	r.HandleFunc("/modem/profile", ph.CreateProfile).Methods("POST")
+	r.HandleFunc("/modem/profile/update", ph.UpdateProfile).Methods("PUT")
+	r.HandleFunc("/modem/profile/delete", ph.DeleteProfile).Methods("DELETE")
 }
 
 func SmsdbRegister(r *mux.Router) {
@@ -68,6 +105,29 @@ func WebhookRegister(r *mux.Router) {
 	r.HandleFunc("/webhook/test", wh.TestWebhook).Methods("POST")
 }
 
+func CampaignRegister(r *mux.Router) {
+	ch := handler.NewCampaignHandler()
+
+	// 群发任务
+	r.HandleFunc("/campaign", ch.CreateCampaign).Methods("POST")
+	r.HandleFunc("/campaign/list", ch.ListCampaigns).Methods("GET")
+	r.HandleFunc("/campaign/get", ch.GetCampaign).Methods("GET")
+	r.HandleFunc("/campaign/pause", ch.PauseCampaign).Methods("POST")
+	r.HandleFunc("/campaign/resume", ch.ResumeCampaign).Methods("POST")
+	r.HandleFunc("/campaign/cancel", ch.CancelCampaign).Methods("POST")
+	r.HandleFunc("/campaign/report", ch.DownloadCampaignReport).Methods("GET")
+}
+
+func RouteRegister(r *mux.Router) {
+	rh := handler.NewRouteHandler()
+
+	// 短信路由规则
+	r.HandleFunc("/route", rh.CreateRoute).Methods("POST")
+	r.HandleFunc("/route/list", rh.ListRoutes).Methods("GET")
+	r.HandleFunc("/route/update", rh.UpdateRoute).Methods("PUT")
+	r.HandleFunc("/route/delete", rh.DeleteRoute).Methods("DELETE")
+}
+
 func SettingRegister(r *mux.Router) {
 	sh := handler.NewSettingHandler()
 
@@ -75,6 +135,19 @@ func SettingRegister(r *mux.Router) {
 	r.HandleFunc("/settings", sh.GetSettings).Methods("GET")
 	r.HandleFunc("/settings/smsdb", sh.UpdateSmsdbSettings).Methods("PUT")
 	r.HandleFunc("/settings/webhook", sh.UpdateWebhookSettings).Methods("PUT")
+	r.HandleFunc("/settings/sender", sh.UpdateSenderSettings).Methods("PUT")
+	r.HandleFunc("/settings/receiver", sh.UpdateReceiverSettings).Methods("PUT")
+}
+
+func SimulatorRegister(r *mux.Router) {
+	sh := handler.NewSimulatorHandler()
+
+	// 虚拟调制解调器
+	r.HandleFunc("/simulator/list", sh.ListSimulators).Methods("GET")
+	r.HandleFunc("/simulator/sms", sh.InjectSms).Methods("POST")
+	r.HandleFunc("/simulator/signal", sh.SetSignal).Methods("POST")
+	r.HandleFunc("/simulator/registration", sh.SetRegistration).Methods("POST")
+	r.HandleFunc("/simulator/service", sh.SetService).Methods("POST")
 }
 
 func WebSocketRegister(r *mux.Router) {
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/models"
+)
+
+// campaignMaxRecipients 单个群发任务的最大收件人数量
+const campaignMaxRecipients = 10000
+
+// templateVar 匹配模板中的变量，如 {{name}}
+var templateVar = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+// Recipient 群发收件人及其模板变量
+type Recipient struct {
+	Number string            `json:"number"`
+	Vars   map[string]string `json:"vars"`
+}
+
+// CampaignService 群发服务
+type CampaignService struct {
+	ms *ModemService
+}
+
+// NewCampaignService 创建群发服务
+func NewCampaignService() *CampaignService {
+	return &CampaignService{ms: GetModemService()}
+}
+
+// ParseRecipientsCSV 解析收件人 CSV，第一行为表头，number 列为号码，其他列作为模板变量
+func ParseRecipientsCSV(r io.Reader) ([]Recipient, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	numberCol := -1
+	for i, h := range header {
+		h = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+		header[i] = h
+		if strings.EqualFold(h, "number") {
+			numberCol = i
+		}
+	}
+	if numberCol < 0 {
+		return nil, fmt.Errorf("csv header must contain a number column")
+	}
+
+	list := []Recipient{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv: %w", err)
+		}
+		rc := Recipient{Number: strings.TrimSpace(row[numberCol]), Vars: map[string]string{}}
+		for i, v := range row {
+			if i != numberCol && header[i] != "" {
+				rc.Vars[header[i]] = v
+			}
+		}
+		list = append(list, rc)
+	}
+	return list, nil
+}
+
+// renderTemplate 替换模板中的变量，{{number}} 为收件人号码，缺少变量时返回错误
+func renderTemplate(tpl string, rc Recipient) (string, error) {
+	var missing []string
+	text := templateVar.ReplaceAllStringFunc(tpl, func(s string) string {
+		key := templateVar.FindStringSubmatch(s)[1]
+		if v, ok := rc.Vars[key]; ok {
+			return v
+		}
+		if key == "number" {
+			return rc.Number
+		}
+		missing = append(missing, key)
+		return s
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing variables: %s", strings.Join(missing, ", "))
+	}
+	return text, nil
+}
+
+// CreateCampaign 为每个收件人生成短信，按顺序轮流分配到设备的发送队列，modems 为空时按路由策略为每个收件人选择设备
+func (s *CampaignService) CreateCampaign(name, tpl string, modems []string, recipients []Recipient, opts SendOptions) (*models.Campaign, error) {
+	if tpl == "" {
+		return nil, fmt.Errorf("template is empty")
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("recipients are empty")
+	}
+	if len(recipients) > campaignMaxRecipients {
+		return nil, fmt.Errorf("too many recipients, max %d", campaignMaxRecipients)
+	}
+	if database.GetDB() == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+	if err := opts.check(); err != nil {
+		return nil, err
+	}
+
+	var senders []routeModem
+	var router *smsRouter
+	var err error
+	if len(modems) > 0 {
+		senders, err = s.resolveModems(modems)
+	} else {
+		router, err = s.ms.newRouter()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 生成每个收件人的短信，任意一行出错时不创建任务
+	now := time.Now()
+	records := make([]models.Sms, len(recipients))
+	jobs := make([]models.SmsJob, len(recipients))
+	names := []string{}
+	for i, rc := range recipients {
+		if rc.Number == "" {
+			return nil, fmt.Errorf("recipient %d: number is empty", i+1)
+		}
+		text, err := renderTemplate(tpl, rc)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %d: %w", i+1, err)
+		}
+		if opts.Transliterate {
+			text = TransliterateGsm(text)
+		}
+		pdus, err := encodeSubmit(rc.Number, text, false, opts.Submit)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %d: %w", i+1, err)
+		}
+
+		var sender *routeModem
+		if router != nil {
+			if sender, err = router.pick(rc.Number, nil); err != nil {
+				return nil, fmt.Errorf("recipient %d: %w", i+1, err)
+			}
+		} else {
+			sender = &senders[i%len(senders)]
+		}
+		if !slices.Contains(names, sender.name) {
+			names = append(names, sender.name)
+		}
+		records[i] = models.Sms{
+			Content:       text,
+			ReceiveTime:   now,
+			ReceiveNumber: rc.Number,
+			SendNumber:    sender.number,
+			Direction:     "out",
+			ModemName:     sender.name,
+			ICCID:         sender.iccid,
+			Segments:      len(pdus),
+			Status:        models.SmsStatusQueued,
+		}
+		jobs[i] = models.SmsJob{
+			ModemName:  sender.name,
+			Number:     rc.Number,
+			Content:    text,
+			Status:     models.SmsJobPending,
+			NextRunAt:  now,
+			SendAt:     opts.SendAt,
+			LatePolicy: opts.LatePolicy,
+			AutoRoute:  router != nil,
+
+			SubmitOptions: opts.Submit,
+		}
+		if opts.SendAt != nil {
+			jobs[i].NextRunAt = *opts.SendAt
+		}
+	}
+
+	if name == "" {
+		name = "campaign-" + now.Format("20060102150405")
+	}
+	campaign := &models.Campaign{
+		Name:     name,
+		Template: tpl,
+		Modems:   strings.Join(names, ","),
+		Status:   models.CampaignRunning,
+		Total:    len(recipients),
+	}
+	if err := database.CreateCampaign(campaign, records, jobs); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Campaign] %d created with %d recipients on %s", campaign.ID, campaign.Total, campaign.Modems)
+	s.emit(campaign)
+	s.wake(campaign)
+	return campaign, nil
+}
+
+// GetCampaign 获取群发任务及各状态的短信任务数量
+func (s *CampaignService) GetCampaign(id int) (*models.Campaign, map[string]int, error) {
+	campaign, err := database.GetCampaign(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	stats, err := database.GetCampaignStats(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.complete(campaign, stats); err != nil {
+		return nil, nil, err
+	}
+	return campaign, stats, nil
+}
+
+// finishJob 群发任务中的短信任务处理完成后检查群发任务是否已完成
+func (s *CampaignService) finishJob(id int) {
+	campaign, err := database.GetCampaign(id)
+	if err != nil || campaign.Status != models.CampaignRunning {
+		return
+	}
+	stats, err := database.GetCampaignStats(id)
+	if err == nil {
+		err = s.complete(campaign, stats)
+	}
+	if err != nil {
+		log.Printf("[Campaign] %d %v", id, err)
+	}
+}
+
+// complete 发送中的群发任务没有待处理的短信时标记为已完成并发布事件
+func (s *CampaignService) complete(campaign *models.Campaign, stats map[string]int) error {
+	active := stats[models.SmsJobPending] + stats[models.SmsJobSending] + stats[models.SmsJobPaused]
+	if campaign.Status != models.CampaignRunning || active > 0 {
+		return nil
+	}
+	ok, err := database.UpdateCampaignStatus(campaign.ID, models.CampaignRunning, models.CampaignCompleted)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// 已被暂停或取消，返回最新状态
+		if latest, err := database.GetCampaign(campaign.ID); err == nil {
+			campaign.Status = latest.Status
+		}
+		return nil
+	}
+	campaign.Status = models.CampaignCompleted
+	log.Printf("[Campaign] %d %s", campaign.ID, campaign.Status)
+	s.emit(campaign)
+	return nil
+}
+
+// PauseCampaign 暂停群发任务，正在发送的短信不受影响
+func (s *CampaignService) PauseCampaign(id int) (*models.Campaign, error) {
+	return s.transition(id, models.CampaignRunning, models.CampaignPaused, func() (int, error) {
+		return database.SetCampaignJobsStatus(id, []string{models.SmsJobPending}, models.SmsJobPaused, "")
+	})
+}
+
+// ResumeCampaign 继续已暂停的群发任务
+func (s *CampaignService) ResumeCampaign(id int) (*models.Campaign, error) {
+	return s.transition(id, models.CampaignPaused, models.CampaignRunning, func() (int, error) {
+		return database.SetCampaignJobsStatus(id, []string{models.SmsJobPaused}, models.SmsJobPending, "")
+	})
+}
+
+// CancelCampaign 取消群发任务中尚未发送的短信
+func (s *CampaignService) CancelCampaign(id int) (*models.Campaign, error) {
+	campaign, err := database.GetCampaign(id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status != models.CampaignRunning && campaign.Status != models.CampaignPaused {
+		return nil, fmt.Errorf("campaign is %s", campaign.Status)
+	}
+	return s.transition(id, campaign.Status, models.CampaignCancelled, func() (int, error) {
+		from := []string{models.SmsJobPending, models.SmsJobPaused}
+		return database.SetCampaignJobsStatus(id, from, models.SmsJobCancelled, models.SmsStatusCancelled)
+	})
+}
+
+// transition 切换群发任务状态并修改其中的短信任务
+func (s *CampaignService) transition(id int, from, to string, update func() (int, error)) (*models.Campaign, error) {
+	campaign, err := database.GetCampaign(id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status != from {
+		return nil, fmt.Errorf("campaign is %s, expected %s", campaign.Status, from)
+	}
+
+	ok, err := database.UpdateCampaignStatus(id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if latest, err := database.GetCampaign(id); err == nil {
+			campaign.Status = latest.Status
+		}
+		return nil, fmt.Errorf("campaign is %s, expected %s", campaign.Status, from)
+	}
+	n, err := update()
+	if err != nil {
+		return nil, err
+	}
+	campaign.Status = to
+
+	log.Printf("[Campaign] %d %s, %d jobs updated", id, to, n)
+	s.emit(campaign)
+	s.wake(campaign)
+	return campaign, nil
+}
+
+// resolveModems 查找发送使用的设备
+func (s *CampaignService) resolveModems(modems []string) ([]routeModem, error) {
+	s.ms.mu.Lock()
+	defer s.ms.mu.Unlock()
+
+	list := []routeModem{}
+	for _, u := range modems {
+		conn := s.ms.lookupConn(u)
+		if conn == nil {
+			return nil, fmt.Errorf("[%s] not found", u)
+		}
+		list = append(list, routeModem{name: conn.Name, number: conn.Number, iccid: conn.ICCID})
+	}
+	return list, nil
+}
+
+// wake 唤醒群发任务所用设备的发送队列
+func (s *CampaignService) wake(campaign *models.Campaign) {
+	s.ms.mu.Lock()
+	defer s.ms.mu.Unlock()
+
+	for _, name := range splitList(campaign.Modems) {
+		if conn := s.ms.pool[name]; conn != nil {
+			conn.wakeSender()
+		}
+	}
+}
+
+// emit 发布群发任务状态事件
+func (s *CampaignService) emit(campaign *models.Campaign) {
+	emitEvent(fmt.Sprintf("campaign-%d", campaign.ID), "campaign", campaign.Status)
+}
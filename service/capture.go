@@ -0,0 +1,93 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rehiy/modem/at"
+
+	"github.com/rehiy/web-modem/capture"
+)
+
+// 抓包文件默认参数
+const (
+	captureDir      = "data/capture"
+	captureMaxSize  = 10 // 单个文件大小，单位 MB
+	captureMaxFiles = 5  // 每台设备保留的文件数量
+)
+
+var (
+	captureOnce     sync.Once
+	captureInstance *capture.Recorder
+)
+
+// getRecorder 返回抓包记录器，未设置 MODEM_CAPTURE_DIR 和 MODEM_CAPTURE_SIZE 或大小为 0 时返回 nil
+func getRecorder() *capture.Recorder {
+	captureOnce.Do(func() {
+		dir := os.Getenv("MODEM_CAPTURE_DIR")
+		v := os.Getenv("MODEM_CAPTURE_SIZE")
+		if dir == "" && v == "" {
+			return
+		}
+		if dir == "" {
+			dir = captureDir
+		}
+		size := captureMaxSize
+		if v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				log.Printf("[Capture] invalid MODEM_CAPTURE_SIZE: %s", v)
+				return
+			}
+			size = n
+		}
+		if size == 0 {
+			log.Printf("[Capture] disabled")
+			return
+		}
+
+		r, err := capture.NewRecorder(dir, int64(size)<<20, captureMaxFiles)
+		if err != nil {
+			log.Printf("[Capture] %v", err)
+			return
+		}
+		captureInstance = r
+	})
+	return captureInstance
+}
+
+// wrapCapture 为端口开启抓包，回放端口和关闭抓包时返回原端口
+func wrapCapture(u string, port at.Port) (at.Port, *capture.Port) {
+	r := getRecorder()
+	if r == nil || strings.HasPrefix(u, capture.ReplayScheme) {
+		return port, nil
+	}
+	cp := r.Wrap(port, path.Base(u), u)
+	return cp, cp
+}
+
+// ListCaptures 返回设备的抓包文件，名称为空时返回全部
+func (m *ModemService) ListCaptures(u string) ([]capture.FileInfo, error) {
+	r := getRecorder()
+	if r == nil {
+		return []capture.FileInfo{}, nil
+	}
+	if u == "" {
+		return r.List("")
+	}
+	return r.List(capture.Key(m.ResolveName(u)))
+}
+
+// CapturePath 返回抓包文件的完整路径
+func CapturePath(name string) (string, error) {
+	r := getRecorder()
+	if r == nil {
+		return "", fmt.Errorf("capture is disabled")
+	}
+	return r.Path(name)
+}
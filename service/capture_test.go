@@ -0,0 +1,31 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/rehiy/web-modem/capture"
+)
+
+// testdata/864412588041188.cap 为模拟器 sim://demo 连接后收到一条短信的抓包
+func TestReplayCapture(t *testing.T) {
+	ms := GetModemService()
+	conn, err := ms.Connect(capture.ReplayScheme + "testdata/864412588041188.cap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ms.Forget(conn.Name) })
+
+	if conn.Name != "864412588041188" || conn.ICCID != "89860009441258804118" || conn.Number != "+8613858804118" {
+		t.Errorf("connected as %s, iccid %s, number %s", conn.Name, conn.ICCID, conn.Number)
+	}
+	if conn.Manufacturer != "WebModem" || conn.Model != "Simulator" {
+		t.Errorf("manufacturer = %s, model = %s", conn.Manufacturer, conn.Model)
+	}
+
+	// 回放 +CMTI 通知后读取并保存短信
+	waitFor(t, "replayed sms", func() bool { return len(receivedSms(t, conn.Name)) > 0 })
+	list := receivedSms(t, conn.Name)
+	if len(list) != 1 || list[0].Content != "replay fixture" || list[0].SendNumber != "+8613800000009" {
+		t.Errorf("received %+v, want one sms from +8613800000009", list)
+	}
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/rehiy/modem/sms"
+	"github.com/rehiy/modem/sms/pdumode"
+	"github.com/rehiy/modem/sms/tpdu"
+
+	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/models"
+)
+
+// concatTimeout 等待长短信其余分段的时间，超时后按已收到的分段保存
+var concatTimeout = 2 * time.Minute
+
+var (
+	receivedRetention = 7 * 24 * time.Hour // 已处理分段记录的保留时间，仍在设备上的分段不清理
+	receivedPrune     = time.Hour          // 清理已处理分段记录的间隔
+)
+
+// concatKey 长短信的标识：设备、发送方、UDH 参考号和分段数量
+type concatKey struct {
+	modem, sender string
+	ref, total    int
+}
+
+// concatBuffer 已收到的长短信分段
+type concatBuffer struct {
+	parts map[int]smsPart // 按分段序号
+	timer *time.Timer
+}
+
+// smsPart 收到的短信分段
+type smsPart struct {
+	index       int // 设备存储中的索引，直接上报的短信为 -1
+	fingerprint string
+	tpdu        *tpdu.TPDU
+}
+
+// receivePdu 处理收到的 SMS-DELIVER，单条短信直接保存，长短信等待分段齐全或超时后再保存，
+// 无效或已处理过的短信返回 false
+func (m *ModemService) receivePdu(conn *ModemConn, data string, index int) bool {
+	pdu, err := pdumode.UnmarshalHexString(data)
+	if err != nil {
+		log.Printf("[%s] invalid sms pdu: %v", conn.Name, err)
+		return false
+	}
+	t, err := sms.Unmarshal(pdu.TPDU)
+	if err != nil {
+		log.Printf("[%s] invalid sms tpdu: %v", conn.Name, err)
+		return false
+	}
+	if t.SmsType() != tpdu.SmsDeliver {
+		log.Printf("[%s] unexpected sms type %s, index %d", conn.Name, t.SmsType(), index)
+		return false
+	}
+
+	part := smsPart{index: index, fingerprint: pduFingerprint(pdu.TPDU), tpdu: t}
+	if !m.claimPdu(conn.Name, part.fingerprint) {
+		return false
+	}
+
+	total, seq, ref, ok := t.ConcatInfo()
+	if !ok || total <= 1 {
+		m.deliverSms(conn.Name, []smsPart{part}, false)
+		return true
+	}
+
+	key := concatKey{conn.Name, t.OA.Number(), ref, total}
+	m.concatMu.Lock()
+	buf := m.concat[key]
+	if buf == nil {
+		buf = &concatBuffer{parts: map[int]smsPart{}}
+		buf.timer = time.AfterFunc(concatTimeout, func() { m.flushConcat(key, buf) })
+		m.concat[key] = buf
+	}
+	if prev, ok := buf.parts[seq]; ok {
+		// 序号相同但内容不同的分段，保留先收到的分段，丢弃新分段
+		delete(m.inflight, conn.Name+"/"+part.fingerprint)
+		m.concatMu.Unlock()
+		log.Printf("[%s] segment %d/%d of ref %d from %s conflicts with index %d, dropped index %d", conn.Name, seq, total, ref, key.sender, prev.index, index)
+		return false
+	}
+	buf.parts[seq] = part
+	complete := len(buf.parts) == total
+	if complete {
+		buf.timer.Stop()
+		delete(m.concat, key)
+	}
+	m.concatMu.Unlock()
+
+	log.Printf("[%s] received segment %d/%d of ref %d from %s", conn.Name, seq, total, ref, key.sender)
+	if complete {
+		m.deliverSms(conn.Name, sortParts(buf.parts), false)
+	}
+	return true
+}
+
+// flushConcat 等待超时，保存已收到的分段并标记为不完整
+func (m *ModemService) flushConcat(key concatKey, buf *concatBuffer) {
+	m.concatMu.Lock()
+	if m.concat[key] != buf {
+		// 已在超时前收齐
+		m.concatMu.Unlock()
+		return
+	}
+	delete(m.concat, key)
+	m.concatMu.Unlock()
+
+	log.Printf("[%s] sms ref %d from %s timed out with %d/%d segments", key.modem, key.ref, key.sender, len(buf.parts), key.total)
+	m.deliverSms(key.modem, sortParts(buf.parts), true)
+}
+
+// deliverSms 合并分段，交给收信流程保存、触发 Webhook 并按删除策略处理设备上的短信
+func (m *ModemService) deliverSms(name string, parts []smsPart, partial bool) {
+	m.mu.Lock()
+	conn := m.pool[name]
+	var number, iccid string
+	if conn != nil {
+		number, iccid = conn.Number, conn.ICCID
+	}
+	m.mu.Unlock()
+
+	segments := make([]*tpdu.TPDU, len(parts))
+	for i, p := range parts {
+		segments[i] = p.tpdu
+	}
+	text, err := sms.Decode(segments)
+	if err != nil {
+		log.Printf("[%s] failed to decode sms: %v", name, err)
+		m.releasePdus(name, parts, 0, nil, false)
+		return
+	}
+
+	first := segments[0]
+	modelSms := &models.Sms{
+		Content:       string(text),
+		SmsIDs:        database.IntArrayToString(storedIndices(parts)),
+		ReceiveTime:   first.SCTS.Time,
+		ReceiveNumber: number,
+		SendNumber:    first.OA.Number(),
+		Direction:     "in",
+		ModemName:     name,
+		ICCID:         iccid,
+		Partial:       partial,
+	}
+	if modelSms.ReceiveTime.IsZero() {
+		modelSms.ReceiveTime = time.Now()
+	}
+	log.Printf("[%s] New Sms from %s: %s", name, modelSms.SendNumber, modelSms.Content)
+	go m.processIncoming(conn, modelSms, parts)
+}
+
+// claimPdu 标记短信分段正在处理，已处理或正在处理时返回 false
+func (m *ModemService) claimPdu(name, fingerprint string) bool {
+	key := name + "/" + fingerprint
+
+	m.concatMu.Lock()
+	defer m.concatMu.Unlock()
+
+	if m.inflight[key] {
+		return false
+	}
+	if database.GetDB() != nil {
+		done, err := database.HasReceivedPdu(name, fingerprint)
+		if err != nil {
+			log.Printf("[%s] %v", name, err)
+		}
+		if done {
+			return false
+		}
+	}
+	m.inflight[key] = true
+	return true
+}
+
+// releasePdus 取消短信分段的处理标记，先记录已保存的短信记录、已成功触发的 Webhook 和是否已完成处理
+func (m *ModemService) releasePdus(name string, parts []smsPart, smsID int, hooked []int, done bool) {
+	fingerprints := partFingerprints(parts)
+	if (done || smsID > 0 || len(hooked) > 0) && database.GetDB() != nil {
+		err := database.SaveReceivedPdus(name, fingerprints, smsID, database.IntArrayToString(hooked), done)
+		if err != nil {
+			log.Printf("[%s] %v", name, err)
+		}
+	}
+
+	m.concatMu.Lock()
+	defer m.concatMu.Unlock()
+	for _, fp := range fingerprints {
+		delete(m.inflight, name+"/"+fp)
+	}
+}
+
+// pruneReceived 定期清理超过保留时间的已处理分段记录，list 为设备上的短信，为空时重新读取
+func (m *ModemService) pruneReceived(conn *ModemConn, list []storedPdu) {
+	if database.GetDB() == nil {
+		return
+	}
+	m.mu.Lock()
+	due := time.Since(conn.pruneAt) >= receivedPrune
+	if due {
+		conn.pruneAt = time.Now()
+	}
+	m.mu.Unlock()
+	if !due {
+		return
+	}
+
+	if list == nil {
+		var err error
+		if list, err = conn.readStoredList(PriorityQuery); err != nil {
+			log.Printf("[%s] failed to list stored Sms: %v", conn.Name, err)
+			return
+		}
+	}
+	keep := []string{}
+	for _, s := range list {
+		if pdu, err := pdumode.UnmarshalHexString(s.pdu); err == nil {
+			keep = append(keep, pduFingerprint(pdu.TPDU))
+		}
+	}
+
+	n, err := database.PruneReceivedPdus(conn.Name, time.Now().Add(-receivedRetention), keep)
+	if err != nil {
+		log.Printf("[%s] %v", conn.Name, err)
+		return
+	}
+	if n > 0 {
+		log.Printf("[%s] pruned %d received pdu records", conn.Name, n)
+	}
+}
+
+// pduFingerprint 计算 TPDU 的指纹
+func pduFingerprint(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// partFingerprints 返回分段的指纹
+func partFingerprints(parts []smsPart) []string {
+	fingerprints := make([]string, len(parts))
+	for i, p := range parts {
+		fingerprints[i] = p.fingerprint
+	}
+	return fingerprints
+}
+
+// storedIndices 返回分段在设备存储中的索引
+func storedIndices(parts []smsPart) []int {
+	indices := []int{}
+	for _, p := range parts {
+		if p.index >= 0 {
+			indices = append(indices, p.index)
+		}
+	}
+	return indices
+}
+
+// sortParts 按分段序号排列
+func sortParts(parts map[int]smsPart) []smsPart {
+	seqs := make([]int, 0, len(parts))
+	for seq := range parts {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+
+	list := make([]smsPart, len(seqs))
+	for i, seq := range seqs {
+		list[i] = parts[seq]
+	}
+	return list
+}
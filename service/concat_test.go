@@ -0,0 +1,38 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rehiy/modem/sms/pdumode"
+
+	"github.com/rehiy/web-modem/database"
+)
+
+func TestPruneReceived(t *testing.T) {
+	const stored = "0891683108100005F0000D91683108000000F90000620171301240000EF2329C1DCE83CC693CBD2E2F03"
+	pdu, err := pdumode.UnmarshalHexString(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	onDevice := pduFingerprint(pdu.TPDU)
+
+	name := "test-prune"
+	if err := database.SaveReceivedPdus(name, []string{onDevice, "removed"}, 1, "", true); err != nil {
+		t.Fatal(err)
+	}
+
+	// 全部记录均已超过保留时间
+	retention := receivedRetention
+	receivedRetention = -time.Hour
+	t.Cleanup(func() { receivedRetention = retention })
+
+	conn := &ModemConn{Name: name}
+	GetModemService().pruneReceived(conn, []storedPdu{{index: 0, stat: 1, pdu: stored}})
+
+	for fp, want := range map[string]bool{onDevice: true, "removed": false} {
+		if done, err := database.HasReceivedPdu(name, fp); err != nil || done != want {
+			t.Errorf("record %s kept = %v, want %v", fp, done, want)
+		}
+	}
+}
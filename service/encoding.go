@@ -0,0 +1,158 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rehiy/modem/sms"
+	"github.com/rehiy/modem/sms/gsm7/charset"
+	"github.com/rehiy/modem/sms/tpdu"
+)
+
+// 短信编码方式
+const (
+	EncodingGsm7 = "gsm7"
+	EncodingUcs2 = "ucs2"
+)
+
+var (
+	gsmCharset    = charset.DefaultEncoder()    // GSM-7 默认字符表
+	gsmExtCharset = charset.DefaultExtEncoder() // GSM-7 扩展字符表，每个字符占用 2 个单位
+)
+
+// gsmTransliterations 非 GSM-7 字符及其相近的 GSM-7 字符
+var gsmTransliterations = buildTransliterations(map[string]string{
+	"'":   "‘’‚‛′`´",
+	"\"":  "“”„‟″«»",
+	"-":   "‐‑‒–—―−",
+	"...": "…",
+	" ":   "      　",
+	"*":   "•·",
+	"a":   "áâãāăąǎ",
+	"A":   "ÁÀÂÃĀĂĄǍ",
+	"c":   "ćĉċč",
+	"C":   "ĆĈĊČ",
+	"d":   "ďđ",
+	"D":   "ĎĐ",
+	"e":   "êëēĕėęě",
+	"E":   "ÈÊËĒĔĖĘĚ",
+	"g":   "ĝğġģ",
+	"G":   "ĜĞĠĢ",
+	"h":   "ĥħ",
+	"H":   "ĤĦ",
+	"i":   "íîïĩīĭįı",
+	"I":   "ÍÌÎÏĨĪĬĮİ",
+	"j":   "ĵ",
+	"J":   "Ĵ",
+	"k":   "ķ",
+	"K":   "Ķ",
+	"l":   "ĺļľŀł",
+	"L":   "ĹĻĽĿŁ",
+	"n":   "ńņňŉ",
+	"N":   "ŃŅŇ",
+	"o":   "óôõōŏőǒ",
+	"O":   "ÓÒÔÕŌŎŐǑ",
+	"r":   "ŕŗř",
+	"R":   "ŔŖŘ",
+	"s":   "śŝşš",
+	"S":   "ŚŜŞŠ",
+	"t":   "ţťŧ",
+	"T":   "ŢŤŦ",
+	"u":   "úûũūŭůűųǔ",
+	"U":   "ÚÙÛŨŪŬŮŰŲǓ",
+	"w":   "ŵ",
+	"W":   "Ŵ",
+	"y":   "ýÿŷ",
+	"Y":   "ÝŶŸ",
+	"z":   "źżž",
+	"Z":   "ŹŻŽ",
+	"oe":  "œ",
+	"OE":  "Œ",
+})
+
+// SmsSegment 单个分段的使用情况，GSM-7 按 7 位字符计算，UCS-2 按 UTF-16 单位计算
+type SmsSegment struct {
+	Used      int `json:"used"`
+	Remaining int `json:"remaining"`
+}
+
+// SmsPreview 短信的编码和分段预览
+type SmsPreview struct {
+	Text           string       `json:"text"`           // 实际发送的内容
+	Transliterated bool         `json:"transliterated"` // 内容是否被替换过
+	Encoding       string       `json:"encoding"`       // 见 Encoding*
+	Characters     int          `json:"characters"`     // 字符数量
+	Segments       []SmsSegment `json:"segments"`
+	NonGsm         []string     `json:"non_gsm"` // 无法用 GSM-7 编码的字符，按出现顺序去重
+}
+
+// PreviewSms 计算短信的编码方式和分段，transliterate 为 true 时先替换为 GSM-7 字符
+func PreviewSms(text string, transliterate bool) (*SmsPreview, error) {
+	if text == "" {
+		return nil, fmt.Errorf("message is required")
+	}
+
+	preview := &SmsPreview{Text: text, NonGsm: []string{}}
+	if transliterate {
+		preview.Text = TransliterateGsm(text)
+		preview.Transliterated = preview.Text != text
+	}
+	preview.Characters = utf8.RuneCountInString(preview.Text)
+
+	for _, r := range preview.Text {
+		if !isGsmRune(r) && !slices.Contains(preview.NonGsm, string(r)) {
+			preview.NonGsm = append(preview.NonGsm, string(r))
+		}
+	}
+
+	tpdus, err := sms.Encode([]byte(preview.Text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sms: %w", err)
+	}
+	for _, t := range tpdus {
+		used, size := len(t.UD), t.UDBlockSize()
+		if alpha, _ := t.Alphabet(); alpha == tpdu.AlphaUCS2 {
+			preview.Encoding = EncodingUcs2
+			used, size = used/2, size/2
+		} else {
+			preview.Encoding = EncodingGsm7
+		}
+		preview.Segments = append(preview.Segments, SmsSegment{Used: used, Remaining: size - used})
+	}
+	return preview, nil
+}
+
+// TransliterateGsm 将智能引号、带重音的字母等非 GSM-7 字符替换为相近的 GSM-7 字符，无法替换的字符保持不变
+func TransliterateGsm(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if rep, ok := gsmTransliterations[r]; ok && !isGsmRune(r) {
+			b.WriteString(rep)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isGsmRune 判断字符是否可以用 GSM-7 默认字符表编码
+func isGsmRune(r rune) bool {
+	_, ok := gsmCharset[r]
+	if !ok {
+		_, ok = gsmExtCharset[r]
+	}
+	return ok
+}
+
+// buildTransliterations 将替换字符与待替换字符的对应关系展开为字符映射
+func buildTransliterations(groups map[string]string) map[rune]string {
+	table := map[rune]string{}
+	for rep, chars := range groups {
+		for _, r := range chars {
+			table[r] = rep
+		}
+	}
+	return table
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPreviewSms(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		encoding string
+		segments []SmsSegment
+	}{
+		{"gsm7", "hello", EncodingGsm7, []SmsSegment{{5, 155}}},
+		{"gsm7 full", strings.Repeat("a", 160), EncodingGsm7, []SmsSegment{{160, 0}}},
+		{"gsm7 concat", strings.Repeat("a", 161), EncodingGsm7, []SmsSegment{{153, 0}, {8, 145}}},
+		{"gsm7 extension", "€", EncodingGsm7, []SmsSegment{{2, 158}}},
+		{"ucs2", "你好", EncodingUcs2, []SmsSegment{{2, 68}}},
+		{"ucs2 full", strings.Repeat("你", 70), EncodingUcs2, []SmsSegment{{70, 0}}},
+		{"ucs2 concat", strings.Repeat("你", 71), EncodingUcs2, []SmsSegment{{67, 0}, {4, 63}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p, err := PreviewSms(c.text, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Encoding != c.encoding {
+				t.Errorf("encoding = %s, want %s", p.Encoding, c.encoding)
+			}
+			if len(p.Segments) != len(c.segments) {
+				t.Fatalf("segments = %v, want %v", p.Segments, c.segments)
+			}
+			for i := range c.segments {
+				if p.Segments[i] != c.segments[i] {
+					t.Errorf("segment %d = %v, want %v", i+1, p.Segments[i], c.segments[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPreviewSmsTransliterate(t *testing.T) {
+	p, err := PreviewSms("“Café” – ok", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Encoding != EncodingGsm7 || !p.Transliterated || len(p.NonGsm) != 0 {
+		t.Errorf("preview = %+v, want transliterated gsm7", p)
+	}
+
+	p, err = PreviewSms("“Café” – ok", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Encoding != EncodingUcs2 || p.Transliterated || len(p.NonGsm) != 3 {
+		t.Errorf("preview = %+v, want ucs2 with 3 non-gsm characters", p)
+	}
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/rehiy/modem/sms"
+	"github.com/rehiy/modem/sms/pdumode"
+	"github.com/rehiy/modem/sms/tpdu"
+
+	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/models"
+)
+
+// processIncoming 保存收到的短信并触发 Webhook，达到删除时机后按设备的删除策略处理设备上的短信；
+// 未达到删除时机的短信保留在设备上，由下次读取已存储短信时重试，已保存的短信不会重复保存，已成功触发的 Webhook 不会重复触发；
+// 保存到数据库后才删除时，短信保存成功后才触发 Webhook
+func (m *ModemService) processIncoming(conn *ModemConn, s *models.Sms, parts []smsPart) {
+	prev := &models.ReceivedPdu{}
+	if database.GetDB() != nil {
+		var err error
+		if prev, err = database.GetReceivedPdu(s.ModemName, partFingerprints(parts)); err != nil {
+			log.Printf("[%s] %v", s.ModemName, err)
+			prev = &models.ReceivedPdu{}
+		}
+	}
+
+	saved := prev.SmsID > 0
+	if saved {
+		s.ID = prev.SmsID
+	} else {
+		var err error
+		if saved, err = NewSmsdbService().HandleIncomingSms(s); err != nil {
+			log.Printf("[%s] %v", s.ModemName, err)
+		}
+	}
+
+	storeFirst := database.GetSmsDeleteAfter() == models.DeleteAfterStored && database.IsSmsdbEnabled()
+	hooked := splitInts(prev.Hooked)
+	done := false
+	if saved || !storeFirst {
+		var err error
+		if hooked, err = NewWebhookService().HandleIncomingSms(s, hooked); err != nil {
+			log.Printf("[Webhook] Failed to trigger webhooks: %v", err)
+		}
+		done = err == nil
+	}
+	if storeFirst {
+		done = saved
+	}
+
+	smsID := 0
+	if saved {
+		smsID = s.ID
+	}
+	m.releasePdus(s.ModemName, parts, smsID, hooked, done)
+
+	indices := storedIndices(parts)
+	if conn == nil || len(indices) == 0 {
+		return
+	}
+	if !done {
+		log.Printf("[%s] Sms not handled, kept on device for retry, indices: %v", s.ModemName, indices)
+		return
+	}
+
+	m.mu.Lock()
+	policy := conn.SmsDelete
+	m.mu.Unlock()
+
+	switch policy {
+	case models.SmsDeleteKeep:
+	case models.SmsDeleteRecent:
+		m.tidyStored(conn, nil)
+	default:
+		m.deleteStored(conn, indices)
+	}
+}
+
+// tidyStored 按设备的删除策略删除设备上已处理的短信，list 为空时重新读取设备上的短信
+func (m *ModemService) tidyStored(conn *ModemConn, list []storedPdu) {
+	m.mu.Lock()
+	policy, keep := conn.SmsDelete, conn.SmsKeep
+	m.mu.Unlock()
+
+	if policy == models.SmsDeleteKeep {
+		m.resetStuck(conn)
+		return
+	}
+	if list == nil {
+		var err error
+		if list, err = conn.readStoredList(PriorityURC); err != nil {
+			log.Printf("[%s] failed to list stored Sms: %v", conn.Name, err)
+			return
+		}
+	}
+
+	msgs := groupStored(list, func(fingerprint string) bool {
+		return m.isProcessed(conn.Name, fingerprint)
+	})
+	if policy != models.SmsDeleteRecent {
+		keep = 0
+	}
+
+	indices := []int{}
+	for i, g := range msgs {
+		if i >= keep {
+			indices = append(indices, g.indices...)
+		}
+	}
+	m.resetStuck(conn)
+	if len(indices) > 0 {
+		m.deleteStored(conn, indices)
+	}
+}
+
+// storedSms 设备上的一条短信，长短信包含多个分段
+type storedSms struct {
+	indices []int
+	time    time.Time
+}
+
+// groupStored 将设备上收到的短信按短信分组，长短信的分段一起保留或删除，只包含 match 返回 true 的分段，按从新到旧排列
+func groupStored(list []storedPdu, match func(fingerprint string) bool) []*storedSms {
+	groups := map[string]*storedSms{}
+	for _, s := range list {
+		if s.stat != 0 && s.stat != 1 {
+			continue
+		}
+		pdu, err := pdumode.UnmarshalHexString(s.pdu)
+		if err != nil {
+			continue
+		}
+		t, err := sms.Unmarshal(pdu.TPDU)
+		if err != nil || t.SmsType() != tpdu.SmsDeliver {
+			continue
+		}
+		if !match(pduFingerprint(pdu.TPDU)) {
+			continue
+		}
+		key := fmt.Sprintf("#%d", s.index)
+		if total, _, ref, ok := t.ConcatInfo(); ok && total > 1 {
+			key = fmt.Sprintf("%s/%d/%d", t.OA.Number(), ref, total)
+		}
+		if g := groups[key]; g != nil {
+			g.indices = append(g.indices, s.index)
+		} else {
+			groups[key] = &storedSms{indices: []int{s.index}, time: t.SCTS.Time}
+		}
+	}
+
+	msgs := make([]*storedSms, 0, len(groups))
+	for _, g := range groups {
+		msgs = append(msgs, g)
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		if !msgs[i].time.Equal(msgs[j].time) {
+			return msgs[i].time.After(msgs[j].time)
+		}
+		return slices.Max(msgs[i].indices) > slices.Max(msgs[j].indices)
+	})
+	return msgs
+}
+
+// deleteStored 删除设备上的短信，删除失败时计入滞留数量
+func (m *ModemService) deleteStored(conn *ModemConn, indices []int) {
+	err := conn.Exec(context.Background(), PriorityURC, "AT+CMGD", func() error {
+		return conn.DeleteSms(indices)
+	})
+	if err != nil {
+		log.Printf("[%s] failed to delete Sms: %v", conn.Name, err)
+		m.markStuck(conn, indices, true)
+		return
+	}
+	log.Printf("[%s] Sms deleted automatically, indices: %v", conn.Name, indices)
+	m.markStuck(conn, indices, false)
+}
+
+// markStuck 更新已处理但删除失败、仍滞留在设备上的短信
+func (m *ModemService) markStuck(conn *ModemConn, indices []int, stuck bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conn.stuck == nil {
+		conn.stuck = map[int]bool{}
+	}
+	for _, i := range indices {
+		if stuck {
+			conn.stuck[i] = true
+		} else {
+			delete(conn.stuck, i)
+		}
+	}
+	conn.Stuck = len(conn.stuck)
+}
+
+// resetStuck 清空滞留的短信，重新检查设备上的短信前调用
+func (m *ModemService) resetStuck(conn *ModemConn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn.stuck = map[int]bool{}
+	conn.Stuck = 0
+}
+
+// isProcessed 检查短信分段是否已处理完成
+func (m *ModemService) isProcessed(name, fingerprint string) bool {
+	m.concatMu.Lock()
+	inflight := m.inflight[name+"/"+fingerprint]
+	m.concatMu.Unlock()
+	if inflight || database.GetDB() == nil {
+		return false
+	}
+	done, err := database.HasReceivedPdu(name, fingerprint)
+	if err != nil {
+		log.Printf("[%s] %v", name, err)
+	}
+	return done
+}
+
+// IsSmsDeletePolicy 检查设备上短信的删除策略是否有效
+func IsSmsDeletePolicy(policy string) bool {
+	switch policy {
+	case "", models.SmsDeleteAll, models.SmsDeleteKeep, models.SmsDeleteRecent:
+		return true
+	}
+	return false
+}
+
+// IsDeleteAfter 检查删除设备上短信的时机是否有效
+func IsDeleteAfter(after string) bool {
+	return after == models.DeleteAfterStored || after == models.DeleteAfterWebhook
+}
+
+// SetSmsPolicy 设置设备上短信的删除策略，keep 为 keep_recent 策略保留的短信数量
+func (m *ModemService) SetSmsPolicy(u, policy string, keep int) (*ModemConn, error) {
+	if !IsSmsDeletePolicy(policy) {
+		return nil, fmt.Errorf("invalid sms delete policy: %s", policy)
+	}
+	if policy == models.SmsDeleteRecent && keep <= 0 {
+		return nil, fmt.Errorf("keep must be positive for %s", policy)
+	}
+	if policy != models.SmsDeleteRecent {
+		keep = 0
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn := m.lookupConn(u)
+	if conn == nil {
+		return nil, fmt.Errorf("[%s] not found", u)
+	}
+	if conn.IMEI == "" {
+		return nil, fmt.Errorf("[%s] has no imei, sms policy not supported", u)
+	}
+	if err := database.SetModemSmsPolicy(conn.IMEI, policy, keep); err != nil {
+		return nil, err
+	}
+	conn.SmsDelete, conn.SmsKeep = policy, keep
+
+	res := *conn
+	return &res, nil
+}
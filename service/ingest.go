@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rehiy/web-modem/database"
+)
+
+// storedPdu 设备上存储的短信
+type storedPdu struct {
+	index int
+	stat  int // 0 未读，1 已读，2 未发送，3 已发送
+	pdu   string
+}
+
+// ingestEnabled 检查是否处理设备上已存储的短信
+func ingestEnabled() bool {
+	return database.GetDB() != nil && database.IsIngestStoredEnabled()
+}
+
+// ingestDue 检查距离上次处理已存储短信是否超过设置的间隔
+func ingestDue(last time.Time) bool {
+	if !ingestEnabled() {
+		return false
+	}
+	interval := database.GetIngestInterval()
+	return interval > 0 && time.Since(last) >= time.Duration(interval)*time.Second
+}
+
+// ingestStored 按收到新短信的流程处理设备上已存储的短信，已处理过的短信不会重复处理
+func (m *ModemService) ingestStored(conn *ModemConn) {
+	m.mu.Lock()
+	conn.ingestAt = time.Now()
+	m.mu.Unlock()
+
+	list, err := conn.readStoredList(PriorityQuery)
+	if err != nil {
+		log.Printf("[%s] failed to list stored Sms: %v", conn.Name, err)
+		return
+	}
+
+	count := 0
+	for _, s := range list {
+		// 只处理收到的短信
+		if s.stat != 0 && s.stat != 1 {
+			continue
+		}
+		if m.receivePdu(conn, s.pdu, s.index) {
+			count++
+		}
+	}
+	if count > 0 {
+		log.Printf("[%s] ingested %d stored Sms segments", conn.Name, count)
+	}
+
+	// 删除已处理但仍在设备上的短信，包括之前删除失败的短信
+	m.tidyStored(conn, list)
+	m.pruneReceived(conn, list)
+}
+
+// readStoredList 按优先级排队读取设备上存储的全部短信
+func (c *ModemConn) readStoredList(priority int) ([]storedPdu, error) {
+	var list []storedPdu
+	err := c.Exec(context.Background(), priority, "AT+CMGL", func() error {
+		var err error
+		list, err = c.listStoredPdus()
+		return err
+	})
+	return list, err
+}
+
+// listStoredPdus 列出设备上存储的全部短信 PDU
+func (c *ModemConn) listStoredPdus() ([]storedPdu, error) {
+	resp, err := c.SendCommand("AT+CMGL=4")
+	if err = commandError(resp, err); err != nil {
+		return nil, err
+	}
+
+	// 响应格式: "+CMGL: <index>,<stat>,[<alpha>],<length>"，下一行为 PDU
+	list := []storedPdu{}
+	for i := 0; i+1 < len(resp); i++ {
+		v, ok := strings.CutPrefix(resp[i], "+CMGL:")
+		if !ok {
+			continue
+		}
+		params := strings.Split(v, ",")
+		if len(params) < 2 {
+			continue
+		}
+		index, err1 := strconv.Atoi(strings.TrimSpace(params[0]))
+		stat, err2 := strconv.Atoi(strings.TrimSpace(params[1]))
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid response: %s", resp[i])
+		}
+		i++
+		list = append(list, storedPdu{index: index, stat: stat, pdu: resp[i]})
+	}
+	return list, nil
+}
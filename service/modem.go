@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -10,10 +11,14 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rehiy/modem/at"
-	"github.com/tarm/serial"
+
+	"github.com/rehiy/web-modem/capture"
+	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/models"
 )
 
 var (
@@ -24,33 +29,94 @@ var (
 
 // ModemConn 端口连接
 type ModemConn struct {
-	Name       string `json:"name"`
-	Number     string `json:"number"`
-	Connected  bool   `json:"connected"`
-	*at.Device `json:"-"`
+	Name         string       `json:"name"` // 稳定标识，优先使用 IMEI
+	Alias        string       `json:"alias"`
+	IMEI         string       `json:"imei"`
+	ICCID        string       `json:"iccid"`
+	Port         string       `json:"port"` // 当前端口路径
+	Number       string       `json:"number"`
+	Manufacturer string       `json:"manufacturer"`
+	Model        string       `json:"model"`
+	Profile      string       `json:"profile"`           // 使用的设备配置档案
+	SmsReceive   string       `json:"sms_receive"`       // 短信接收方式，见 SmsReceive*
+	SmsDelete    string       `json:"sms_delete"`        // 设备上短信的删除策略，见 models.SmsDelete*
+	SmsKeep      int          `json:"sms_keep"`          // keep_recent 策略保留的短信数量
+	Stuck        int          `json:"stuck"`             // 已处理但删除失败、滞留在设备上的短信分段数量
+	Storage      []SmsStorage `json:"storage,omitempty"` // 短信存储的使用情况，定期刷新
+	Connected    bool         `json:"connected"`
+	Operator     string       `json:"operator"` // 注册的运营商，定期刷新
+	Signal       int          `json:"signal"`   // 信号强度 rssi，99 表示未知
+	Reconnects   int          `json:"reconnects"`
+	LastError    string       `json:"last_error"`
+	Ignored      bool         `json:"ignored"` // 端口已手动断开，自动扫描时忽略
+	USB          *USBInfo     `json:"usb,omitempty"`
+	Queue        QueueStats   `json:"queue"`
+	*at.Device   `json:"-"`
+	profile      *models.ModemProfile
+	queue        *cmdQueue         // 命令调度队列
+	stop         chan struct{}     // 停止连接监护
+	submits      chan submitResult // 短信提交结果
+	wake         chan struct{}     // 唤醒短信发送队列
+	netAt        time.Time         // 上次刷新运营商和信号强度的时间
+	ackSms       bool              // 直接上报的短信和状态报告需要 AT+CNMA 确认
+	ingestAt     time.Time         // 上次处理设备上已存储短信的时间
+	pruneAt      time.Time         // 上次清理已处理分段记录的时间
+	stuck        map[int]bool      // 已处理但删除失败的短信索引
+	storageAt    time.Time         // 上次查询短信存储使用情况的时间
+	warned       map[string]bool   // 已发布告警的存储区域
+}
+
+// deviceInfo 打开设备时识别的信息
+type deviceInfo struct {
+	Manufacturer string
+	Model        string
+	Profile      *models.ModemProfile
+	SmsReceive   string
+	ackSms       bool
+	capture      *capture.Port // 抓包端口，未开启抓包时为 nil
 }
 
 // ModemService 管理多个串口连接
 type ModemService struct {
-	pool map[string]*ModemConn
-	mu   sync.Mutex
+	pool       map[string]*ModemConn
+	watched    map[string]bool   // 上次检测到的端口
+	retry      map[string]bool   // 已检测到但尚未连接成功的端口，每次检测时重试
+	params     map[string]string // MODEM_PORT 中端口的串口参数
+	ignored    map[string]bool   // 手动断开的端口，不再自动连接
+	connecting map[string]bool   // 正在连接的端口
+	mu         sync.Mutex
+
+	scans  map[string]*ScanJob // 最近的扫描任务
+	scanMu sync.Mutex
+
+	concat   map[concatKey]*concatBuffer // 等待其余分段的长短信
+	inflight map[string]bool             // 正在处理的短信分段，键为设备标识和指纹
+	concatMu sync.Mutex
+
+	routeSeq atomic.Uint64 // 路由轮转位置
+	reportMu sync.Mutex    // 依次处理状态报告，通知在各自的协程中处理，长短信各分段的报告可能同时到达
 }
 
 // GetModemService 返回单例实例
 func GetModemService() *ModemService {
 	modemOnce.Do(func() {
 		modemInstance = &ModemService{
-			pool: map[string]*ModemConn{},
+			pool:       map[string]*ModemConn{},
+			watched:    map[string]bool{},
+			retry:      map[string]bool{},
+			params:     map[string]string{},
+			ignored:    map[string]bool{},
+			connecting: map[string]bool{},
+			scans:      map[string]*ScanJob{},
+			concat:     map[concatKey]*concatBuffer{},
+			inflight:   map[string]bool{},
 		}
 	})
 	return modemInstance
 }
 
-// ScanModems 扫描可用的调制解调器并连接到它们
-func (m *ModemService) ScanModems(devs ...string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
+// findPorts 返回潜在的设备端口列表，并记录端口描述中的串口参数，调用方需持有锁
+func (m *ModemService) findPorts(devs ...string) []string {
 	// 环境变量
 	if len(devs) == 0 {
 		port := os.Getenv("MODEM_PORT")
@@ -65,22 +131,33 @@ func (m *ModemService) ScanModems(devs ...string) {
 		if len(devs) == 0 {
 			devs = []string{"COM1", "COM2", "COM3", "COM4", "COM5"}
 		}
+		for i, p := range devs {
+			u, query := splitPortSpec(strings.TrimSpace(p))
+			m.params[u] = query
+			devs[i] = u
+		}
 	default:
 		if len(devs) == 0 {
 			devs = []string{"/dev/ttyUSB*", "/dev/ttyACM*"}
 		}
 		pps := []string{}
 		for _, p := range devs {
-			matches, _ := filepath.Glob(p)
+			pattern, query := splitPortSpec(strings.TrimSpace(p))
+			if isVirtualPort(pattern) {
+				m.params[pattern] = query
+				pps = append(pps, pattern)
+				continue
+			}
+			matches, _ := filepath.Glob(pattern)
+			for _, u := range matches {
+				m.params[u] = query
+			}
 			pps = append(pps, matches...)
 		}
 		devs = pps
 	}
 
-	// 尝试连接到新设备
-	for _, u := range devs {
-		m.makeConnect(u)
-	}
+	return devs
 }
 
 // GetConnList 返回已连接的端口信息
@@ -90,147 +167,568 @@ func (m *ModemService) GetConnList() []*ModemConn {
 
 	var conns []*ModemConn
 	for _, model := range m.pool {
-		conns = append(conns, model)
+		conn := *model // 返回副本，避免与连接监护并发读写
+		conn.Ignored = m.ignored[conn.Port]
+		conn.Queue = model.queue.stats(false)
+		conns = append(conns, &conn)
 	}
 	return conns
 }
 
-// GetConn 返回给定端口名称的 AT 接口
+// GetConn 返回给定名称的 AT 接口，名称可以是 IMEI、别名或端口名
 func (m *ModemService) GetConn(u string) (*ModemConn, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	n := path.Base(u)
-	conn, ok := m.pool[n]
-	if !ok {
-		return nil, fmt.Errorf("[%s] not found", n)
+	conn := m.lookupConn(u)
+	if conn == nil {
+		return nil, fmt.Errorf("[%s] not found", u)
 	}
 	if !conn.Connected || conn.Device == nil || conn.Device.IsOpen() == false {
-		return nil, fmt.Errorf("[%s] not connected", n)
+		return nil, fmt.Errorf("[%s] not connected", u)
 	}
 	return conn, nil
 }
 
-// handleIncomingSms 处理指定端口的新接收短信
-func (m *ModemService) handleIncomingSms(portName string, smsIndex int) {
-	conn, err := m.GetConn(portName)
+// GetQueue 返回设备命令队列的状态
+func (m *ModemService) GetQueue(u string) (*QueueStats, error) {
+	m.mu.Lock()
+	conn := m.lookupConn(u)
+	m.mu.Unlock()
+
+	if conn == nil {
+		return nil, fmt.Errorf("[%s] not found", u)
+	}
+	stats := conn.queue.stats(true)
+	return &stats, nil
+}
+
+// ResolveName 将别名或端口名转换为稳定标识
+func (m *ModemService) ResolveName(u string) string {
+	m.mu.Lock()
+	conn := m.lookupConn(u)
+	m.mu.Unlock()
+
+	if conn != nil {
+		return conn.Name
+	}
+	if modem, err := database.GetModemByAlias(u); err == nil {
+		return modem.IMEI
+	}
+	return u
+}
+
+// SetAlias 设置设备别名
+func (m *ModemService) SetAlias(u, alias string) (*ModemConn, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn := m.lookupConn(u)
+	if conn == nil {
+		return nil, fmt.Errorf("[%s] not found", u)
+	}
+	if conn.IMEI == "" {
+		return nil, fmt.Errorf("[%s] has no imei, alias not supported", u)
+	}
+	if err := database.SetModemAlias(conn.IMEI, alias); err != nil {
+		return nil, err
+	}
+	conn.Alias = alias
+
+	res := *conn
+	return &res, nil
+}
+
+// Connect 连接指定端口，并恢复自动扫描，名称可以是端口路径、IMEI 或别名
+func (m *ModemService) Connect(u string) (*ModemConn, error) {
+	m.mu.Lock()
+	if conn := m.lookupConn(u); conn != nil {
+		u = conn.Port
+	} else {
+		port, query := splitPortSpec(strings.TrimSpace(u))
+		if query != "" {
+			m.params[port] = query
+		}
+		u = port
+	}
+	delete(m.ignored, u)
+	m.mu.Unlock()
+
+	if err := m.makeConnect(u, nil); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn := m.connByPort(u)
+	if conn == nil {
+		return nil, fmt.Errorf("[%s] not found", u)
+	}
+	emitEvent(conn.Name, "connected", u)
+
+	res := *conn
+	return &res, nil
+}
+
+// Disconnect 断开设备并释放端口，自动扫描和重连将忽略该端口，直到再次连接
+func (m *ModemService) Disconnect(u string) (*ModemConn, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn := m.lookupConn(u)
+	if conn == nil {
+		return nil, fmt.Errorf("[%s] not found", u)
+	}
+
+	m.ignored[conn.Port] = true
+	if conn.Connected {
+		conn.Connected = false
+		conn.Close()
+		log.Printf("[%s] disconnected, port %s released", conn.Name, conn.Port)
+		emitEvent(conn.Name, "disconnected", conn.Port)
+	}
+
+	res := *conn
+	res.Ignored = true
+	return &res, nil
+}
+
+// Forget 断开设备并从连接池中移除，端口仍存在时由热插拔检测重新识别和连接（手动断开的端口除外）
+func (m *ModemService) Forget(u string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn := m.lookupConn(u)
+	if conn == nil {
+		return fmt.Errorf("[%s] not found", u)
+	}
+
+	if conn.Connected {
+		conn.Connected = false
+		conn.Close()
+	}
+	close(conn.stop)
+	delete(m.pool, conn.Name)
+	delete(m.watched, conn.Port)
+	log.Printf("[%s] removed from pool", conn.Name)
+	emitEvent(conn.Name, "forgotten", conn.Port)
+
+	return nil
+}
+
+// lookupConn 按标识、别名或端口名查找连接，调用方需持有锁
+func (m *ModemService) lookupConn(u string) *ModemConn {
+	if conn, ok := m.pool[u]; ok {
+		return conn
+	}
+	for _, conn := range m.pool {
+		if conn.Alias != "" && conn.Alias == u {
+			return conn
+		}
+	}
+	for _, conn := range m.pool {
+		if conn.Port == u || path.Base(conn.Port) == path.Base(u) {
+			return conn
+		}
+	}
+	return nil
+}
+
+// connByPort 按端口路径查找连接，优先返回已连接的设备，调用方需持有锁
+func (m *ModemService) connByPort(u string) *ModemConn {
+	var found *ModemConn
+	for _, conn := range m.pool {
+		if conn.Port != u {
+			continue
+		}
+		if conn.Connected {
+			return conn
+		}
+		found = conn
+	}
+	return found
+}
+
+// handleIncomingSms 读取 +CMTI 通知的新短信，长短信的分段交给重组缓存
+func (m *ModemService) handleIncomingSms(u string, smsIndex int) {
+	conn, err := m.GetConn(u)
 	if err != nil {
-		log.Printf("[%s] Failed to get connection for incoming Sms: %v", portName, err)
+		log.Printf("[%s] Failed to get connection for incoming Sms: %v", u, err)
 		return
 	}
 
-	// 获取短信列表（只获取新短信）
-	smsList, err := conn.ListSmsPdu(4)
+	hex, err := conn.readStoredPdu(smsIndex)
 	if err != nil {
-		log.Printf("[%s] Failed to list Sms: %v", portName, err)
+		log.Printf("[%s] Failed to read Sms %d: %v", conn.Name, smsIndex, err)
+		return
+	}
+	if hex == "" {
+		log.Printf("[%s] Sms %d not found", conn.Name, smsIndex)
 		return
 	}
 
-	smsdbService := NewSmsdbService()
-	webhookService := NewWebhookService()
+	if !m.receivePdu(conn, hex, smsIndex) {
+		log.Printf("[%s] Sms %d skipped", conn.Name, smsIndex)
+	}
+}
 
-	// 处理每条短信
-	for _, atSms := range smsList {
-		hasNewSms := false
-		for _, idx := range atSms.Indices {
-			if idx == smsIndex {
-				hasNewSms = true
+// handleDirectSms 处理 +CMT 直接上报的短信，确认后交给重组缓存，不读写设备存储
+func (m *ModemService) handleDirectSms(u, hex string) {
+	conn, err := m.GetConn(u)
+	if err != nil {
+		log.Printf("[%s] Failed to get connection for incoming Sms: %v", u, err)
+		return
+	}
+
+	m.ackDirect(u)
+	m.receivePdu(conn, hex, -1)
+}
+
+// ackDirect 按需使用 AT+CNMA 确认直接上报的短信或状态报告
+func (m *ModemService) ackDirect(u string) {
+	conn, err := m.GetConn(u)
+	if err != nil || !conn.ackSms {
+		return
+	}
+	err = conn.Exec(context.Background(), PriorityURC, "AT+CNMA", func() error {
+		resp, err := conn.SendCommand("AT+CNMA")
+		return commandError(resp, err)
+	})
+	if err != nil {
+		log.Printf("[%s] failed to acknowledge Sms: %v", conn.Name, err)
+	}
+}
+
+// readStoredPdu 读取存储的短信 PDU，短信不存在时返回空字符串
+func (c *ModemConn) readStoredPdu(index int) (string, error) {
+	var hex string
+	err := c.Exec(context.Background(), PriorityURC, "AT+CMGR", func() error {
+		resp, err := c.SendCommand(fmt.Sprintf("AT+CMGR=%d", index))
+		if err = commandError(resp, err); err != nil {
+			return err
+		}
+		for i, line := range resp {
+			if strings.HasPrefix(line, "+CMGR:") && i+1 < len(resp) {
+				hex = resp[i+1]
 				break
 			}
 		}
-		if hasNewSms {
-			log.Printf("[%s] New Sms from %s: %s", portName, atSms.Number, atSms.Text)
-			modelSms := atSmsToModelSms(atSms, conn.Number, conn.Name)
-			smsdbService.HandleIncomingSms(modelSms)
-			webhookService.HandleIncomingSms(modelSms)
-			// 自动删除设备上的短信
-			go func() {
-				if err := conn.DeleteSms(atSms.Indices); err != nil {
-					log.Printf("[%s] failed to delete Sms: %v", portName, err)
-				} else {
-					log.Printf("[%s] Sms deleted automatically, indices: %v", portName, atSms.Indices)
-				}
-			}()
+		return nil
+	})
+	return hex, err
+}
+
+// DeleteSms 删除设备上的短信，超时或返回错误时失败，需在命令队列中执行
+func (c *ModemConn) DeleteSms(indices []int) error {
+	for _, index := range indices {
+		resp, err := c.SendCommand(fmt.Sprintf("AT+CMGD=%d", index))
+		if err = commandError(resp, err); err != nil {
+			return fmt.Errorf("delete sms %d: %w", index, err)
 		}
 	}
+	return nil
 }
 
-// makeConnect 添加新的 AT 接口
-func (m *ModemService) makeConnect(u string) error {
+// makeConnect 打开端口并加入连接池，探测设备期间不持有锁，progress 用于报告进度
+func (m *ModemService) makeConnect(u string, progress func(string)) error {
 	n := path.Base(u)
+	report := func(s string) {
+		if progress != nil {
+			progress(s)
+		}
+	}
 
-	// 创建日志函数
-	pf := func(s string, v ...any) {
-		log.Printf(fmt.Sprintf("[%s] %s", n, s), v...)
+	// 同一端口同时只允许一个连接流程
+	m.mu.Lock()
+	if m.connecting[u] {
+		m.mu.Unlock()
+		return fmt.Errorf("[%s] connection in progress", n)
 	}
+	m.connecting[u] = true
+	conn := m.connByPort(u)
+	var dev *at.Device
+	if conn != nil && conn.Connected {
+		dev = conn.Device
+	}
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.connecting, u)
+		m.mu.Unlock()
+	}()
 
 	// 检查是否已连接
-	if conn, ok := m.pool[n]; ok {
-		if conn.Test() == nil {
-			pf("already connected")
+	if dev != nil {
+		if dev.Test() == nil {
+			log.Printf("[%s] already connected", n)
+			report("at ok")
 			return nil
 		}
-		conn.Connected = false
-		conn.Close()
+		m.mu.Lock()
+		if conn.Device == dev && conn.Connected {
+			conn.Connected = false
+			dev.Close()
+		}
+		m.mu.Unlock()
+	}
+
+	// 打开设备
+	report("opening")
+	modem, info, err := m.openDevice(u)
+	if err != nil {
+		m.mu.Lock()
+		if conn != nil {
+			conn.LastError = err.Error()
+		}
+		m.mu.Unlock()
+		report("failed: " + err.Error())
+		return err
+	}
+	report("at ok")
+
+	// 查询设备身份，优先使用 IMEI 作为稳定标识
+	imei, iccid := "", ""
+	if v, err := modem.GetIMEI(); err == nil {
+		imei = cleanIdent(v)
+	}
+	if v, err := modem.GetICCID(); err == nil {
+		iccid = cleanIdent(v)
+	}
+	id := imei
+	if id == "" {
+		log.Printf("[%s] failed to get imei, fallback to port name", n)
+		id = n
+	}
+	if info.capture != nil {
+		info.capture.Bind(id)
+	}
+
+	// 记录设备身份，读取别名和短信删除策略
+	var saved *models.Modem
+	if imei != "" && database.GetDB() != nil {
+		if model, err := database.TouchModem(imei, iccid, u); err == nil {
+			saved = model
+		} else {
+			log.Printf("[%s] failed to save modem identity: %v", n, err)
+		}
+	}
+
+	// 获取手机号，用于接收号码
+	usb := readUSBInfo(u)
+	number := ""
+	if hasQuirk(info.Profile, QuirkNoCnum) {
+		log.Printf("[%s] connected as %s, profile: %s", n, id, info.Profile.Name)
+	} else if v, _, err := modem.GetNumber(); err == nil {
+		log.Printf("[%s] connected as %s, phone number: %s", n, id, v)
+		number = v
+	} else {
+		log.Printf("[%s] connected as %s, but failed to get phone number: %v", n, id, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 探测期间端口被手动断开
+	if m.ignored[u] {
+		modem.Close()
+		return fmt.Errorf("[%s] port is disconnected manually", n)
+	}
+
+	// 端口上换了另一台设备
+	conn = m.connByPort(u)
+	if conn != nil && conn.Name != id {
+		log.Printf("[%s] device changed from %s to %s", n, conn.Name, id)
+		conn = nil
+	}
+
+	// 添加到连接池
+	if conn == nil {
+		conn = m.pool[id]
+	}
+	if conn == nil {
+		conn = &ModemConn{
+			Name:    id,
+			IMEI:    imei,
+			Number:  "unkown",
+			Signal:  99,
+			queue:   newCmdQueue(),
+			stop:    make(chan struct{}),
+			submits: make(chan submitResult, 1),
+			wake:    make(chan struct{}, 1),
+		}
+		m.pool[id] = conn
+		go conn.queue.run(conn.stop)
+		go m.superviseConn(conn)
+		go m.runSender(conn)
+	} else if conn.Connected && conn.Device != nil {
+		conn.Close() // 同一设备从其他端口重新出现
+	}
+	conn.Port = u
+	conn.USB = usb
+	conn.ICCID = iccid
+	conn.Manufacturer = info.Manufacturer
+	conn.Model = info.Model
+	conn.Profile = info.Profile.Name
+	conn.profile = info.Profile
+	conn.SmsReceive = info.SmsReceive
+	conn.ackSms = info.ackSms
+	conn.Connected = true
+	conn.LastError = ""
+	conn.Device = modem
+	if saved != nil {
+		conn.Alias = saved.Alias
+		conn.SmsDelete, conn.SmsKeep = saved.SmsDelete, saved.SmsKeep
+	}
+	if number != "" {
+		conn.Number = number
+	}
+
+	// 处理服务停止或断开期间收到的短信
+	if ingestEnabled() {
+		go m.ingestStored(conn)
+	}
+	go m.refreshStorage(conn)
+
+	return nil
+}
+
+// openDevice 打开串口，按设备配置档案执行初始化序列
+func (m *ModemService) openDevice(u string) (*at.Device, *deviceInfo, error) {
+	n := path.Base(u)
+
+	// 创建日志函数
+	pf := func(s string, v ...any) {
+		log.Printf(fmt.Sprintf("[%s] %s", n, s), v...)
 	}
 
 	// 创建事件处理函数
 	hf := func(e string, p map[int]string) {
-		select {
-		case ModemEvent <- fmt.Sprintf("%s, %s, %v", n, e, p):
-		default:
-			log.Printf("[%s] urc dropped: %s", n, e)
+		emitEvent(m.nameOfPort(u), e, p)
+		// 短信提交结果
+		if e == "+CMGS" || e == "+CMS ERROR" {
+			m.notifySubmit(u, e, p)
+		}
+		// 短信状态报告，PDU 由 pduPort 合并为最后一个参数
+		if e == "+CDS" && len(p) > 1 {
+			m.ackDirect(u)
+			m.handleStatusReport(u, p[len(p)-1])
+		}
+		if e == "+CDSI" && len(p) > 1 {
+			if index, err := strconv.Atoi(p[1]); err == nil {
+				m.handleStoredReport(u, index)
+			}
+		}
+		// 直接上报的短信，PDU 由 pduPort 合并为最后一个参数
+		if e == "+CMT" && len(p) > 1 {
+			m.handleDirectSms(u, p[len(p)-1])
 		}
 		// 处理收到的短信通知
 		if e == "+CMTI" && len(p) > 0 {
 			if indexStr, ok := p[1]; ok {
 				if index, err := strconv.Atoi(indexStr); err == nil {
-					m.handleIncomingSms(n, index)
+					m.handleIncomingSms(u, index)
 				}
 			}
 		}
 	}
 
+	// 计算串口参数
+	sc, err := m.portConfig(u)
+	if err != nil {
+		pf("invalid port config: %v", err)
+		return nil, nil, err
+	}
+
 	// 打开串口
-	pf("connecting")
-	port, err := serial.OpenPort(&serial.Config{
-		Name:        u,      // 串口完整路径
-		Baud:        115200, // 波特率
-		ReadTimeout: 1 * time.Second,
-	})
+	pf("connecting, baud: %d, format: %d%s%d", sc.Baud, sc.DataBits, sc.Parity, sc.StopBits)
+	port, err := openPort(u, sc)
 	if err != nil {
 		pf("connect failed: %v", err)
-		return err
+		return nil, nil, err
 	}
 
+	// 记录收发数据
+	info := &deviceInfo{}
+	port, info.capture = wrapCapture(u, port)
+	port = newPduPort(port)
+
 	// 链接新设备
-	modem := at.New(port, hf, &at.Config{Printf: pf})
+	modem := at.New(port, hf, &at.Config{Timeout: sc.CommandTimeout, ResponseSet: responseSet(), Printf: pf})
 	if err := modem.Test(); err != nil {
 		pf("at test failed: %v", err)
 		modem.Close()
-		return err
+		return nil, nil, err
 	}
 
-	// 设置默认参数
-	modem.EchoOff()     // 关闭回显
-	modem.SetSmsMode(0) // PDU 模式
-	modem.SetSmsStore("ME", "ME", "ME")
+	// 匹配设备配置档案
+	if v, err := modem.GetManufacturer(); err == nil {
+		info.Manufacturer = cleanInfo(v)
+	}
+	if v, err := modem.GetModel(); err == nil {
+		info.Model = cleanInfo(v)
+	}
+	info.Profile, err = matchProfile(sc.Profile, info.Manufacturer, info.Model)
+	if err != nil {
+		pf("match profile failed: %v", err)
+		modem.Close()
+		return nil, nil, err
+	}
+	pf("manufacturer: %s, model: %s, profile: %s", info.Manufacturer, info.Model, info.Profile.Name)
 
-	// 添加到连接池
-	m.pool[n] = &ModemConn{
-		Name:      n,
-		Number:    "unkown",
-		Connected: true,
-		Device:    modem,
+	// 执行初始化序列
+	applyProfile(modem, info.Profile, pf)
+	var cnmi string
+	info.SmsReceive, cnmi = applyReceiveMode(modem, info.Profile, sc.SmsReceive, pf)
+	info.ackSms = needsAck(modem, cnmi)
+
+	return modem, info, nil
+}
+
+// nameOfPort 返回端口当前对应的设备标识
+func (m *ModemService) nameOfPort(u string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conn := m.connByPort(u); conn != nil {
+		return conn.Name
 	}
+	return path.Base(u)
+}
 
-	// 获取手机号，用于接收号码
-	if number, _, err := modem.GetNumber(); err == nil {
-		pf("connected, phone number: %s", number)
-		m.pool[n].Number = number
-	} else {
-		pf("connected, but failed to get phone number: %v", err)
+// cleanIdent 清理 IMEI/ICCID 查询结果中的前缀和引号
+func cleanIdent(s string) string {
+	if i := strings.Index(s, ":"); i >= 0 {
+		s = s[i+1:]
+	}
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if len(s) < 10 {
+		return ""
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'F') && (c < 'a' || c > 'f') {
+			return ""
+		}
 	}
+	return s
+}
 
-	return nil
+// cleanInfo 清理厂商、型号查询结果中的前缀和引号
+func cleanInfo(s string) string {
+	if strings.HasPrefix(s, "+") {
+		if i := strings.Index(s, ":"); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+	return strings.Trim(strings.TrimSpace(s), `"`)
+}
+
+// emitEvent 向事件流发布消息，通道已满时丢弃
+func emitEvent(name, event string, payload any) {
+	select {
+	case ModemEvent <- fmt.Sprintf("%s, %s, %v", name, event, payload):
+	default:
+		log.Printf("[%s] event dropped: %s", name, event)
+	}
 }
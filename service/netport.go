@@ -0,0 +1,329 @@
+package service
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const dialTimeout = 5 * time.Second
+
+// tcpKeepAlive TCP 保活参数，空闲 15 秒后每 5 秒探测一次，连续 3 次无响应时断开，
+// 及时发现半开连接，不依赖连接监护的 AT 探测
+var tcpKeepAlive = net.KeepAliveConfig{
+	Enable:   true,
+	Idle:     15 * time.Second,
+	Interval: 5 * time.Second,
+	Count:    3,
+}
+
+// isNetworkPort 判断是否为网络端口，例如 tcp://host:port 或 rfc2217://host:port
+func isNetworkPort(u string) bool {
+	return strings.HasPrefix(u, "tcp://") || strings.HasPrefix(u, "rfc2217://")
+}
+
+// tcpPort 原始 TCP 端口（ser2net raw 模式等）
+type tcpPort struct {
+	net.Conn
+}
+
+// Flush 无需刷新
+func (p *tcpPort) Flush() error {
+	return nil
+}
+
+// dialTCP 连接原始 TCP 端口
+func dialTCP(u string) (*tcpPort, error) {
+	addr, err := parseNetworkAddr(u)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialNetwork(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &tcpPort{conn}, nil
+}
+
+// dialNetwork 建立 TCP 连接并开启保活
+func dialNetwork(addr string) (net.Conn, error) {
+	d := net.Dialer{Timeout: dialTimeout, KeepAliveConfig: tcpKeepAlive}
+	return d.Dial("tcp", addr)
+}
+
+// parseNetworkAddr 从网络端口地址中提取 host:port
+func parseNetworkAddr(u string) (string, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return "", fmt.Errorf("invalid network port %q: %w", u, err)
+	}
+	if pu.Host == "" || pu.Port() == "" {
+		return "", fmt.Errorf("invalid network port %q: host and port required", u)
+	}
+	return pu.Host, nil
+}
+
+// ===== RFC 2217 =====
+
+// Telnet 命令及选项
+const (
+	telnetIAC  = 255
+	telnetDONT = 254
+	telnetDO   = 253
+	telnetWONT = 252
+	telnetWILL = 251
+	telnetSB   = 250
+	telnetSE   = 240
+
+	telnetBinary  = 0
+	telnetSGA     = 3
+	telnetComPort = 44
+)
+
+// RFC 2217 子命令，服务端响应为对应值 +100
+const (
+	comSetBaudrate = 1
+	comSetDatasize = 2
+	comSetParity   = 3
+	comSetStopsize = 4
+	comSetControl  = 5
+	comServerBase  = 100
+)
+
+// Telnet 解析状态
+const (
+	stateData = iota
+	stateIAC
+	stateOption
+	stateSB
+	stateSBIAC
+)
+
+// rfc2217Port 基于 Telnet COM-PORT-OPTION 的远程串口
+type rfc2217Port struct {
+	conn    net.Conn
+	wmu     sync.Mutex
+	state   int
+	verb    byte
+	sbuf    []byte
+	pending []byte
+	raw     []byte
+	refused bool   // 服务端拒绝 COM-PORT-OPTION
+	baud    uint32 // 服务端确认的波特率
+}
+
+// dialRFC2217 连接远程串口并协商串口参数
+func dialRFC2217(u string, sc PortConfig) (*rfc2217Port, error) {
+	addr, err := parseNetworkAddr(u)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialNetwork(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &rfc2217Port{conn: conn, raw: make([]byte, 1024)}
+	if err := p.negotiate(sc); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// negotiate 启用 COM-PORT-OPTION 并设置串口参数，等待服务端确认波特率
+func (p *rfc2217Port) negotiate(sc PortConfig) error {
+	p.sendCommand(telnetWILL, telnetComPort)
+	p.sendCommand(telnetWILL, telnetBinary)
+	p.sendCommand(telnetDO, telnetBinary)
+	p.sendCommand(telnetWILL, telnetSGA)
+	p.sendCommand(telnetDO, telnetSGA)
+
+	baud := make([]byte, 4)
+	binary.BigEndian.PutUint32(baud, uint32(sc.Baud))
+	parity := map[string]byte{"N": 1, "O": 2, "E": 3, "M": 4, "S": 5}[sc.Parity]
+	stopsize := map[int]byte{1: 1, 2: 2, 15: 3}[sc.StopBits]
+	control := byte(1)
+	if sc.RTSCTS {
+		control = 3
+	}
+
+	p.sendSubnegotiation(comSetBaudrate, baud...)
+	p.sendSubnegotiation(comSetDatasize, byte(sc.DataBits))
+	p.sendSubnegotiation(comSetParity, parity)
+	p.sendSubnegotiation(comSetStopsize, stopsize)
+	p.sendSubnegotiation(comSetControl, control)
+
+	// 等待波特率确认
+	deadline := time.Now().Add(max(3*time.Second, sc.CommandTimeout))
+	p.conn.SetReadDeadline(deadline)
+	defer p.conn.SetReadDeadline(time.Time{})
+
+	for p.baud == 0 {
+		if err := p.fill(); err != nil {
+			return fmt.Errorf("rfc2217 negotiation failed: %w", err)
+		}
+		if p.refused {
+			return fmt.Errorf("rfc2217 not supported by server")
+		}
+	}
+	if p.baud != uint32(sc.Baud) {
+		return fmt.Errorf("rfc2217 server set baud rate to %d instead of %d", p.baud, sc.Baud)
+	}
+
+	return nil
+}
+
+// Read 读取串口数据，过滤 Telnet 协商内容
+func (p *rfc2217Port) Read(b []byte) (int, error) {
+	for len(p.pending) == 0 {
+		if err := p.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, p.pending)
+	p.pending = p.pending[n:]
+	return n, nil
+}
+
+// Write 写入串口数据，转义 IAC 字节
+func (p *rfc2217Port) Write(b []byte) (int, error) {
+	buf := make([]byte, 0, len(b))
+	for _, c := range b {
+		buf = append(buf, c)
+		if c == telnetIAC {
+			buf = append(buf, telnetIAC)
+		}
+	}
+
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+
+	if _, err := p.conn.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Flush 无需刷新
+func (p *rfc2217Port) Flush() error {
+	return nil
+}
+
+// Close 关闭连接
+func (p *rfc2217Port) Close() error {
+	return p.conn.Close()
+}
+
+// fill 从连接读取一批数据并解析
+func (p *rfc2217Port) fill() error {
+	n, err := p.conn.Read(p.raw)
+	for _, c := range p.raw[:n] {
+		p.parse(c)
+	}
+	return err
+}
+
+// parse 逐字节解析 Telnet 数据流
+func (p *rfc2217Port) parse(c byte) {
+	switch p.state {
+	case stateData:
+		if c == telnetIAC {
+			p.state = stateIAC
+			return
+		}
+		p.pending = append(p.pending, c)
+	case stateIAC:
+		switch c {
+		case telnetIAC:
+			p.pending = append(p.pending, c)
+			p.state = stateData
+		case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+			p.verb = c
+			p.state = stateOption
+		case telnetSB:
+			p.sbuf = p.sbuf[:0]
+			p.state = stateSB
+		default:
+			p.state = stateData
+		}
+	case stateOption:
+		p.handleOption(p.verb, c)
+		p.state = stateData
+	case stateSB:
+		if c == telnetIAC {
+			p.state = stateSBIAC
+			return
+		}
+		p.sbuf = append(p.sbuf, c)
+	case stateSBIAC:
+		switch c {
+		case telnetSE:
+			p.handleSubnegotiation(p.sbuf)
+			p.state = stateData
+		case telnetIAC:
+			p.sbuf = append(p.sbuf, c)
+			p.state = stateSB
+		default:
+			p.state = stateData
+		}
+	}
+}
+
+// handleOption 响应服务端的选项协商
+func (p *rfc2217Port) handleOption(verb, opt byte) {
+	supported := opt == telnetBinary || opt == telnetSGA || opt == telnetComPort
+	switch verb {
+	case telnetDO:
+		if !supported {
+			p.sendCommand(telnetWONT, opt)
+		}
+	case telnetWILL:
+		if !supported {
+			p.sendCommand(telnetDONT, opt)
+		}
+	case telnetDONT:
+		if opt == telnetComPort {
+			p.refused = true
+		}
+	}
+}
+
+// handleSubnegotiation 处理服务端的 COM-PORT-OPTION 响应
+func (p *rfc2217Port) handleSubnegotiation(sb []byte) {
+	if len(sb) < 2 || sb[0] != telnetComPort {
+		return
+	}
+	if sb[1] == comServerBase+comSetBaudrate && len(sb) >= 6 {
+		p.baud = binary.BigEndian.Uint32(sb[2:6])
+	}
+}
+
+// sendCommand 发送 Telnet 选项命令
+func (p *rfc2217Port) sendCommand(verb, opt byte) {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	p.conn.Write([]byte{telnetIAC, verb, opt})
+}
+
+// sendSubnegotiation 发送 COM-PORT-OPTION 子命令
+func (p *rfc2217Port) sendSubnegotiation(cmd byte, value ...byte) {
+	buf := []byte{telnetIAC, telnetSB, telnetComPort, cmd}
+	for _, c := range value {
+		buf = append(buf, c)
+		if c == telnetIAC {
+			buf = append(buf, telnetIAC)
+		}
+	}
+	buf = append(buf, telnetIAC, telnetSE)
+
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	p.conn.Write(buf)
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSer2net 模拟 ser2net 的 RFC 2217 服务端，按 reply 返回确认的波特率，refuse 时拒绝 COM-PORT-OPTION
+type fakeSer2net struct {
+	ln     net.Listener
+	reply  func(baud uint32) uint32
+	refuse bool
+	greet  []byte // 协商完成后发送给客户端的数据
+}
+
+func startSer2net(t *testing.T, s *fakeSer2net) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ln = ln
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return "rfc2217://" + ln.Addr().String()
+}
+
+func (s *fakeSer2net) serve() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		if c != telnetIAC {
+			continue
+		}
+		verb, _ := r.ReadByte()
+		switch verb {
+		case telnetWILL:
+			opt, _ := r.ReadByte()
+			if opt == telnetComPort && s.refuse {
+				conn.Write([]byte{telnetIAC, telnetDONT, telnetComPort})
+			}
+		case telnetDO, telnetWONT, telnetDONT:
+			r.ReadByte()
+		case telnetSB:
+			sb := readSubnegotiation(r)
+			if len(sb) == 6 && sb[0] == telnetComPort && sb[1] == comSetBaudrate {
+				baud := make([]byte, 4)
+				binary.BigEndian.PutUint32(baud, s.reply(binary.BigEndian.Uint32(sb[2:6])))
+				resp := []byte{telnetIAC, telnetSB, telnetComPort, comServerBase + comSetBaudrate}
+				resp = append(resp, baud...)
+				resp = append(resp, telnetIAC, telnetSE)
+				conn.Write(resp)
+				conn.Write(s.greet)
+			}
+		}
+	}
+}
+
+// readSubnegotiation 读取 IAC SB 之后到 IAC SE 为止的内容
+func readSubnegotiation(r *bufio.Reader) []byte {
+	var sb []byte
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return sb
+		}
+		if c == telnetIAC {
+			next, _ := r.ReadByte()
+			if next == telnetSE {
+				return sb
+			}
+		}
+		sb = append(sb, c)
+	}
+}
+
+func testPortConfig(baud int) PortConfig {
+	sc := defaultPortConfig()
+	sc.Baud = baud
+	sc.CommandTimeout = time.Second
+	return sc
+}
+
+func TestDialRFC2217(t *testing.T) {
+	s := &fakeSer2net{
+		reply: func(baud uint32) uint32 { return baud },
+		greet: []byte{'O', 'K', telnetIAC, telnetIAC, '\r', '\n'},
+	}
+	u := startSer2net(t, s)
+
+	p, err := dialRFC2217(u+"?baud=9600", testPortConfig(9600))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer p.Close()
+
+	if p.baud != 9600 {
+		t.Errorf("baud = %d, want 9600", p.baud)
+	}
+
+	// 服务端转义的 IAC 还原为单个字节
+	buf := make([]byte, 5)
+	p.conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(p, buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if want := []byte{'O', 'K', telnetIAC, '\r', '\n'}; string(buf) != string(want) {
+		t.Errorf("data = %q, want %q", buf, want)
+	}
+}
+
+func TestDialRFC2217BaudMismatch(t *testing.T) {
+	s := &fakeSer2net{reply: func(uint32) uint32 { return 115200 }}
+	u := startSer2net(t, s)
+
+	_, err := dialRFC2217(u, testPortConfig(9600))
+	if err == nil || !strings.Contains(err.Error(), "115200 instead of 9600") {
+		t.Fatalf("err = %v, want baud mismatch", err)
+	}
+}
+
+func TestDialRFC2217Refused(t *testing.T) {
+	s := &fakeSer2net{reply: func(baud uint32) uint32 { return baud }, refuse: true}
+	u := startSer2net(t, s)
+
+	_, err := dialRFC2217(u, testPortConfig(9600))
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Fatalf("err = %v, want refused", err)
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"bytes"
+
+	"github.com/rehiy/modem/at"
+)
+
+// pduUrcs PDU 模式下数据在下一行的通知
+var pduUrcs = [][]byte{[]byte("+CDS:"), []byte("+CMT:")}
+
+// pduPort 将 +CDS、+CMT 通知与下一行的 PDU 合并为一行，
+// 例如 "+CDS: 25" 和 "0791..." 合并为 "+CDS: 25,0791..."，PDU 作为通知的最后一个参数；
+// 没有换行的短信输入提示符 "> " 补全为一行，以便作为 AT+CMGS 的响应读取
+type pduPort struct {
+	at.Port
+	buf    []byte // 未处理的数据
+	out    []byte // 待读取的数据
+	header []byte // 等待 PDU 的通知行
+}
+
+// newPduPort 包装端口
+func newPduPort(port at.Port) *pduPort {
+	return &pduPort{Port: port}
+}
+
+// Read 读取数据，合并通知和 PDU
+func (p *pduPort) Read(b []byte) (int, error) {
+	for len(p.out) == 0 {
+		n, err := p.Port.Read(b)
+		p.buf = append(p.buf, b[:n]...)
+		p.process()
+		if err != nil {
+			if len(p.out) == 0 {
+				return 0, err
+			}
+			break
+		}
+	}
+	n := copy(b, p.out)
+	p.out = p.out[n:]
+	return n, nil
+}
+
+// process 按行处理缓存的数据
+func (p *pduPort) process() {
+	for len(p.buf) > 0 {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			if p.header == nil && bytes.Equal(bytes.TrimSpace(p.buf), []byte(">")) {
+				p.out = append(p.out, '>', '\r', '\n')
+				p.buf = p.buf[:0]
+				return
+			}
+			// 其他没有换行的数据直接输出，通知行等待完整
+			if p.header == nil && !bytes.HasPrefix(bytes.TrimLeft(p.buf, "\r"), []byte("+")) {
+				p.out = append(p.out, p.buf...)
+				p.buf = p.buf[:0]
+			}
+			return
+		}
+
+		line := p.buf[:i+1]
+		text := bytes.TrimSpace(line)
+		p.buf = p.buf[i+1:]
+
+		switch {
+		case p.header != nil && len(text) == 0:
+			// 通知和 PDU 之间的空行
+		case p.header != nil:
+			if isHex(text) {
+				p.out = append(p.out, p.header...)
+				p.out = append(p.out, ',')
+				p.out = append(p.out, text...)
+				p.out = append(p.out, '\r', '\n')
+			} else {
+				p.out = append(p.out, p.header...)
+				p.out = append(p.out, '\r', '\n')
+				p.out = append(p.out, line...)
+			}
+			p.header = nil
+		case isPduUrc(text):
+			p.header = append([]byte{}, text...)
+		default:
+			p.out = append(p.out, line...)
+		}
+	}
+}
+
+// isPduUrc 判断是否为数据在下一行的通知
+func isPduUrc(line []byte) bool {
+	for _, prefix := range pduUrcs {
+		if bytes.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHex 判断是否为十六进制字符串
+func isHex(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for _, c := range b {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'F') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
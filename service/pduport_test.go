@@ -0,0 +1,84 @@
+package service
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// chunkPort 按给定的分块返回数据的端口
+type chunkPort struct {
+	chunks []string
+}
+
+func (p *chunkPort) Read(b []byte) (int, error) {
+	if len(p.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, p.chunks[0])
+	if p.chunks[0] = p.chunks[0][n:]; p.chunks[0] == "" {
+		p.chunks = p.chunks[1:]
+	}
+	return n, nil
+}
+
+func (p *chunkPort) Write(b []byte) (int, error) { return len(b), nil }
+func (p *chunkPort) Flush() error                { return nil }
+func (p *chunkPort) Close() error                { return nil }
+
+func TestPduPort(t *testing.T) {
+	cases := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{
+			"cmt",
+			[]string{"\r\n+CMT: ,22\r\n0791683108100005F0\r\n"},
+			"\r\n+CMT: ,22,0791683108100005F0\r\n",
+		},
+		{
+			"split",
+			[]string{"\r\n+CD", "S: 22\r", "\n07916831", "08100005F0\r\n", "\r\nOK\r\n"},
+			"\r\n+CDS: 22,0791683108100005F0\r\n\r\nOK\r\n",
+		},
+		{
+			"blank line",
+			[]string{"+CMT: ,22\r\n\r\n0791683108100005F0\r\n"},
+			"+CMT: ,22,0791683108100005F0\r\n",
+		},
+		{
+			"text mode",
+			[]string{"+CMT: \"+8613800000000\",,\"24/01/01,00:00:00+32\"\r\nhello\r\n"},
+			"+CMT: \"+8613800000000\",,\"24/01/01,00:00:00+32\"\r\nhello\r\n",
+		},
+		{
+			"prompt",
+			[]string{"\r\n> "},
+			"\r\n>\r\n",
+		},
+		{
+			"other",
+			[]string{"\r\n+CSQ: 20,99\r\n\r\nOK\r\n"},
+			"\r\n+CSQ: 20,99\r\n\r\nOK\r\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := newPduPort(&chunkPort{chunks: c.chunks})
+			var sb strings.Builder
+			buf := make([]byte, 8)
+			for {
+				n, err := p.Read(buf)
+				sb.Write(buf[:n])
+				if err != nil {
+					break
+				}
+			}
+			if got := sb.String(); got != c.want {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rehiy/modem/at"
+
+	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/models"
+)
+
+// 兼容性选项
+const (
+	QuirkNoEchoOff = "no-echo-off" // 不发送 ATE0
+	QuirkNoCnum    = "no-cnum"     // 不查询本机号码（AT+CNUM）
+	QuirkNoSmsMode = "no-sms-mode" // 不设置 PDU 模式（AT+CMGF）
+)
+
+// 短信接收方式
+const (
+	SmsReceiveStore  = "store"  // 存储到设备后通过 +CMTI 通知
+	SmsReceiveDirect = "direct" // 通过 +CMT 直接上报，不占用设备存储
+)
+
+// defaultProfile 未匹配到配置档案时使用
+const defaultProfile = "generic"
+
+// builtinProfiles 内置设备配置档案，按顺序匹配
+var builtinProfiles = []models.ModemProfile{
+	{
+		Name:         "quectel",
+		Manufacturer: `(?i)quectel`,
+		SmsStore:     "ME",
+		Cnmi:         "2,1,0,1,0", // 新短信 +CMTI，状态报告 +CDS
+	},
+	{
+		Name:         "simcom",
+		Manufacturer: `(?i)sim\s*com`,
+		SmsStore:     "SM",
+		Cnmi:         "2,1,0,1,0",
+	},
+	{
+		Name:         "huawei",
+		Manufacturer: `(?i)huawei`,
+		InitScript:   "AT^CURC=0", // 关闭周期性状态上报
+		SmsStore:     "SM",
+		Cnmi:         "2,1,0,2,0", // 状态报告存储后 +CDSI 通知
+	},
+	{
+		Name:     defaultProfile,
+		SmsStore: "ME",
+		Cnmi:     "2,1,0,1,0",
+	},
+}
+
+// ListProfiles 返回自定义和内置的设备配置档案，自定义档案优先
+func ListProfiles() ([]models.ModemProfile, error) {
+	profiles := []models.ModemProfile{}
+	if database.GetDB() != nil {
+		list, err := database.GetProfileList()
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, list...)
+	}
+	for _, p := range builtinProfiles {
+		p.Builtin = true
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// CheckProfile 检查设备配置档案是否有效
+func CheckProfile(p *models.ModemProfile) error {
+	if p.Name == "" {
+		return fmt.Errorf("name is empty")
+	}
+	for _, b := range builtinProfiles {
+		if b.Name == p.Name {
+			return fmt.Errorf("name %q is reserved by builtin profile", p.Name)
+		}
+	}
+	if _, err := regexp.Compile(p.Manufacturer); err != nil {
+		return fmt.Errorf("invalid manufacturer pattern: %w", err)
+	}
+	if _, err := regexp.Compile(p.Model); err != nil {
+		return fmt.Errorf("invalid model pattern: %w", err)
+	}
+	if n := len(splitList(p.SmsStore)); n != 0 && n != 1 && n != 3 {
+		return fmt.Errorf("invalid sms store: %s", p.SmsStore)
+	}
+	return nil
+}
+
+// matchProfile 选择设备配置档案：指定名称 > 按厂商和型号匹配 > 通用档案
+func matchProfile(name, manufacturer, model string) (*models.ModemProfile, error) {
+	profiles, err := ListProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		for _, p := range profiles {
+			if p.Name == name {
+				return &p, nil
+			}
+		}
+		return nil, fmt.Errorf("profile %q not found", name)
+	}
+
+	for _, p := range profiles {
+		// 未设置匹配规则的档案只能被指定使用
+		if p.Manufacturer == "" && p.Model == "" {
+			continue
+		}
+		if matchPattern(p.Manufacturer, manufacturer) && matchPattern(p.Model, model) {
+			return &p, nil
+		}
+	}
+
+	for _, p := range profiles {
+		if p.Name == defaultProfile {
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("profile %q not found", defaultProfile)
+}
+
+// matchPattern 检查字符串是否匹配正则表达式，空表达式匹配任意值
+func matchPattern(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	re, err := regexp.Compile(pattern)
+	return err == nil && re.MatchString(s)
+}
+
+// applyProfile 按配置档案执行设备初始化
+func applyProfile(modem *at.Device, p *models.ModemProfile, pf func(string, ...any)) {
+	if !hasQuirk(p, QuirkNoEchoOff) {
+		modem.EchoOff() // 关闭回显
+	}
+	if !hasQuirk(p, QuirkNoSmsMode) {
+		modem.SetSmsMode(0) // PDU 模式
+	}
+
+	// 短信存储，设置失败时回退到 SIM 卡
+	if store := splitList(p.SmsStore); len(store) > 0 {
+		for len(store) < 3 {
+			store = append(store, store[0])
+		}
+		if err := modem.SetSmsStore(store[0], store[1], store[2]); err != nil {
+			pf("set sms store %v failed, fallback to SM: %v", store, err)
+			modem.SetSmsStore("SM", "SM", "SM")
+		}
+	}
+
+	// 新短信通知
+	if p.Cnmi != "" {
+		if _, err := modem.SendCommand("AT+CNMI=" + p.Cnmi); err != nil {
+			pf("set cnmi %s failed: %v", p.Cnmi, err)
+		}
+	}
+
+	// 自定义初始化命令
+	for _, cmd := range strings.Split(p.InitScript, "\n") {
+		cmd = strings.TrimSpace(cmd)
+		if cmd == "" || strings.HasPrefix(cmd, "#") {
+			continue
+		}
+		if _, err := modem.SendCommand(cmd); err != nil {
+			pf("init command %s failed: %v", cmd, err)
+		}
+	}
+}
+
+// applyReceiveMode 设置短信接收方式，返回实际使用的方式及生效的 AT+CNMI 参数
+func applyReceiveMode(modem *at.Device, p *models.ModemProfile, mode string, pf func(string, ...any)) (string, string) {
+	if mode != SmsReceiveDirect {
+		return SmsReceiveStore, p.Cnmi
+	}
+
+	// 保留档案中的其他 AT+CNMI 参数，只将新短信改为直接上报
+	cnmi := strings.Split(p.Cnmi, ",")
+	if p.Cnmi == "" {
+		cnmi = []string{"2", "1", "0", "1", "0"}
+	}
+	for len(cnmi) < 2 {
+		cnmi = append(cnmi, "0")
+	}
+	cnmi[1] = "2"
+	if _, err := modem.SendCommand("AT+CNMI=" + strings.Join(cnmi, ",")); err != nil {
+		pf("set direct sms receive failed, fallback to store: %v", err)
+		if p.Cnmi != "" {
+			modem.SendCommand("AT+CNMI=" + p.Cnmi)
+		}
+		return SmsReceiveStore, p.Cnmi
+	}
+	return SmsReceiveDirect, strings.Join(cnmi, ",")
+}
+
+// needsAck 检查直接上报的短信（+CMT）和状态报告（+CDS）是否需要 AT+CNMA 确认：
+// AT+CNMI 中 mt 为 2/3 或 ds 为 1 时为直接上报，消息服务为 1（Phase 2+）时需要确认，否则设备会重发或停止上报；
+// 优先使用设备当前的 AT+CNMI 设置（可能被自定义初始化命令修改），查询失败时使用 cnmi
+func needsAck(modem *at.Device, cnmi string) bool {
+	if resp, err := modem.SendCommand("AT+CNMI?"); err == nil {
+		for _, line := range resp {
+			if v, ok := strings.CutPrefix(line, "+CNMI:"); ok {
+				cnmi = v
+			}
+		}
+	}
+
+	params := strings.Split(cnmi, ",")
+	param := func(i int) string {
+		if i < len(params) {
+			return strings.TrimSpace(params[i])
+		}
+		return ""
+	}
+	if mt, ds := param(1), param(3); mt != "2" && mt != "3" && ds != "1" {
+		return false
+	}
+
+	resp, err := modem.SendCommand("AT+CSMS?")
+	if err != nil {
+		return false
+	}
+	for _, line := range resp {
+		if v, ok := strings.CutPrefix(line, "+CSMS:"); ok {
+			return strings.TrimSpace(strings.Split(v, ",")[0]) == "1"
+		}
+	}
+	return false
+}
+
+// hasQuirk 检查配置档案是否包含指定兼容性选项
+func hasQuirk(p *models.ModemProfile, quirk string) bool {
+	if p == nil {
+		return false
+	}
+	for _, q := range splitList(p.Quirks) {
+		if q == quirk {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList 拆分逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// splitInts 拆分逗号分隔的整数列表，忽略无效项
+func splitInts(s string) []int {
+	var list []int
+	for _, v := range splitList(s) {
+		if n, err := strconv.Atoi(v); err == nil {
+			list = append(list, n)
+		}
+	}
+	return list
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rehiy/modem/at"
+	"github.com/rehiy/web-modem/simulator"
+)
+
+func TestNeedsAck(t *testing.T) {
+	cases := []struct {
+		name    string
+		service int
+		cnmi    string
+		want    bool
+	}{
+		{"store-phase2", 0, "2,1,0,2,0", false},
+		{"store-phase2plus", 1, "2,1,0,2,0", false},
+		{"direct-phase2", 0, "2,2,0,0,0", false},
+		{"direct-phase2plus", 1, "2,2,0,0,0", true},
+		// 存储接收时 +CDS 直接上报也需要确认
+		{"report-phase2plus", 1, "2,1,0,1,0", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			name := "test-ack-" + c.name
+			if err := simulator.Get(name).SetService(c.service); err != nil {
+				t.Fatal(err)
+			}
+			port, err := simulator.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			modem := at.New(port, func(string, map[int]string) {}, &at.Config{Timeout: time.Second})
+			defer modem.Close()
+
+			if _, err := modem.SendCommand("AT+CNMI=" + c.cnmi); err != nil {
+				t.Fatal(err)
+			}
+			if got := needsAck(modem, c.cnmi); got != c.want {
+				t.Errorf("needsAck = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 命令优先级，数值越小越先执行
+const (
+	PriorityURC   = iota // 通知处理，如读取新短信
+	PrioritySend         // 发送短信
+	PriorityQuery        // 界面查询
+)
+
+// priorityNames 优先级名称
+var priorityNames = map[int]string{
+	PriorityURC:   "urc",
+	PrioritySend:  "send",
+	PriorityQuery: "query",
+}
+
+// commandTimeouts 未指定截止时间时，各优先级命令的默认超时（含排队时间）
+var commandTimeouts = map[int]time.Duration{
+	PriorityURC:   time.Minute,
+	PrioritySend:  2 * time.Minute,
+	PriorityQuery: 30 * time.Second,
+}
+
+// QueueTask 队列中的命令
+type QueueTask struct {
+	Name      string     `json:"name"`
+	Priority  string     `json:"priority"`
+	QueuedAt  time.Time  `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+// QueueStats 命令队列状态
+type QueueStats struct {
+	Depth    int         `json:"depth"`
+	InFlight *QueueTask  `json:"in_flight,omitempty"`
+	Pending  []QueueTask `json:"pending,omitempty"`
+}
+
+// cmdTask 待执行的命令
+type cmdTask struct {
+	name      string
+	priority  int
+	seq       uint64
+	fn        func() error
+	done      chan error
+	queuedAt  time.Time
+	startedAt time.Time
+	index     int // 在堆中的位置，-1 表示已出队
+}
+
+// info 返回命令的展示信息
+func (t *cmdTask) info() QueueTask {
+	res := QueueTask{Name: t.name, Priority: priorityNames[t.priority], QueuedAt: t.queuedAt}
+	if !t.startedAt.IsZero() {
+		started := t.startedAt
+		res.StartedAt = &started
+	}
+	return res
+}
+
+// taskHeap 按优先级和入队顺序排序的命令堆
+type taskHeap []*cmdTask
+
+func (h taskHeap) Len() int { return len(h) }
+
+func (h taskHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority < h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h taskHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *taskHeap) Push(x any) {
+	t := x.(*cmdTask)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *taskHeap) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
+
+// cmdQueue 单个设备的命令调度队列，按优先级串行执行
+type cmdQueue struct {
+	mu       sync.Mutex
+	tasks    taskHeap
+	seq      uint64
+	inflight *cmdTask
+	stopped  bool
+	notify   chan struct{}
+}
+
+// newCmdQueue 创建命令队列
+func newCmdQueue() *cmdQueue {
+	return &cmdQueue{notify: make(chan struct{}, 1)}
+}
+
+// push 将命令加入队列
+func (q *cmdQueue) push(t *cmdTask) error {
+	q.mu.Lock()
+	if q.stopped {
+		q.mu.Unlock()
+		return fmt.Errorf("command queue stopped")
+	}
+	q.seq++
+	t.seq = q.seq
+	t.queuedAt = time.Now()
+	heap.Push(&q.tasks, t)
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// remove 移除尚未执行的命令，命令已开始执行时返回 false
+func (q *cmdQueue) remove(t *cmdTask) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove(&q.tasks, t.index)
+	return true
+}
+
+// run 依次执行队列中的命令，直到 stop 关闭
+func (q *cmdQueue) run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			q.close()
+			return
+		default:
+		}
+
+		q.mu.Lock()
+		if len(q.tasks) == 0 {
+			q.mu.Unlock()
+			select {
+			case <-stop:
+				q.close()
+				return
+			case <-q.notify:
+			}
+			continue
+		}
+		t := heap.Pop(&q.tasks).(*cmdTask)
+		t.startedAt = time.Now()
+		q.inflight = t
+		q.mu.Unlock()
+
+		err := t.fn()
+
+		q.mu.Lock()
+		q.inflight = nil
+		q.mu.Unlock()
+		t.done <- err
+	}
+}
+
+// close 停止队列，拒绝所有等待中的命令
+func (q *cmdQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.stopped = true
+	for len(q.tasks) > 0 {
+		t := heap.Pop(&q.tasks).(*cmdTask)
+		t.done <- fmt.Errorf("command queue stopped")
+	}
+}
+
+// stats 返回队列状态，detail 为 true 时包含等待中的命令
+func (q *cmdQueue) stats(detail bool) QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	res := QueueStats{Depth: len(q.tasks)}
+	if q.inflight != nil {
+		info := q.inflight.info()
+		res.InFlight = &info
+	}
+	if detail {
+		pending := make(taskHeap, len(q.tasks))
+		copy(pending, q.tasks)
+		sort.Slice(pending, func(i, j int) bool { return pending.Less(i, j) })
+		for _, t := range pending {
+			res.Pending = append(res.Pending, t.info())
+		}
+	}
+	return res
+}
+
+// Exec 通过命令队列执行设备操作，ctx 取消时移除尚未执行的命令；已开始执行的命令会等待其完成
+func (c *ModemConn) Exec(ctx context.Context, priority int, name string, fn func() error) error {
+	if c.queue == nil {
+		return fn()
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, commandTimeouts[priority])
+		defer cancel()
+	}
+
+	t := &cmdTask{name: name, priority: priority, fn: fn, done: make(chan error, 1)}
+	if err := c.queue.push(t); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-t.done:
+		return err
+	case <-ctx.Done():
+		if c.queue.remove(t) {
+			return fmt.Errorf("[%s] %s: %w", c.Name, name, ctx.Err())
+		}
+		return <-t.done
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rehiy/modem/sms"
+	"github.com/rehiy/modem/sms/pdumode"
+	"github.com/rehiy/modem/sms/tpdu"
+
+	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/models"
+)
+
+// SmsReport 短信状态报告
+type SmsReport struct {
+	SmsID         int       `json:"sms_id"`
+	MessageRef    int       `json:"message_ref"`
+	Recipient     string    `json:"recipient"`
+	Status        string    `json:"status"`         // 更新后的发送状态
+	ReportStatus  int       `json:"report_status"`  // TP-ST 状态码
+	DischargeTime time.Time `json:"discharge_time"` // 投递或最终失败的时间
+}
+
+// handleStatusReport 处理 +CDS 上报的状态报告
+func (m *ModemService) handleStatusReport(u, hex string) {
+	if err := m.applyStatusReport(u, hex); err != nil {
+		log.Printf("[%s] status report: %v", m.nameOfPort(u), err)
+	}
+}
+
+// handleStoredReport 处理 +CDSI 通知，读取存储的状态报告后删除
+func (m *ModemService) handleStoredReport(u string, index int) {
+	conn, err := m.GetConn(u)
+	if err != nil {
+		log.Printf("[%s] Failed to get connection for status report: %v", u, err)
+		return
+	}
+
+	hex, err := conn.readStoredPdu(index)
+	if err == nil && hex != "" {
+		err = conn.Exec(context.Background(), PriorityURC, "AT+CMGD", func() error {
+			return conn.DeleteSms([]int{index})
+		})
+	}
+	if err != nil {
+		log.Printf("[%s] failed to read status report %d: %v", conn.Name, index, err)
+		return
+	}
+	if hex == "" {
+		log.Printf("[%s] status report %d not found", conn.Name, index)
+		return
+	}
+
+	m.handleStatusReport(u, hex)
+}
+
+// applyStatusReport 解析状态报告，按消息参考号更新发送记录
+func (m *ModemService) applyStatusReport(u, hex string) error {
+	pdu, err := pdumode.UnmarshalHexString(hex)
+	if err != nil {
+		return fmt.Errorf("invalid pdu: %w", err)
+	}
+	t, err := sms.Unmarshal(pdu.TPDU)
+	if err != nil {
+		return fmt.Errorf("invalid tpdu: %w", err)
+	}
+	if t.SmsType() != tpdu.SmsStatusReport {
+		return fmt.Errorf("unexpected tpdu type: %s", t.SmsType())
+	}
+
+	name := m.nameOfPort(u)
+	report := &SmsReport{
+		MessageRef:    int(t.MR),
+		Recipient:     t.RA.Number(),
+		ReportStatus:  int(t.ST),
+		DischargeTime: t.DT.Time,
+	}
+	log.Printf("[%s] status report for mr %d to %s: 0x%02x", name, report.MessageRef, report.Recipient, t.ST)
+
+	if database.GetDB() == nil {
+		return nil
+	}
+
+	m.reportMu.Lock()
+	defer m.reportMu.Unlock()
+
+	record, err := database.FindSmsByMessageRef(name, report.MessageRef)
+	if err != nil {
+		return fmt.Errorf("mr %d: %w", report.MessageRef, err)
+	}
+
+	if !updateReported(record, report.MessageRef, t.ST, report.DischargeTime) {
+		return nil
+	}
+	if err := database.UpdateSmsStatus(record); err != nil {
+		return err
+	}
+
+	report.SmsID = record.ID
+	report.Status = record.Status
+	emitEvent(name, "sms_report", report)
+	if record.Status != models.SmsStatusSent {
+		NewWebhookService().HandleSmsStatus(record)
+	}
+	return nil
+}
+
+// updateReported 按状态报告更新发送记录，记录有变化时返回 true
+// TP-ST 0x00-0x1F 已投递，0x20-0x3F 短信中心仍在重试，0x40 以上投递失败（TS 23.040 9.2.3.15）
+func updateReported(record *models.Sms, mr int, st byte, dt time.Time) bool {
+	switch {
+	case st < 0x20:
+		reported := splitList(record.Reported)
+		if !slices.Contains(reported, strconv.Itoa(mr)) {
+			reported = append(reported, strconv.Itoa(mr))
+		}
+		record.Reported = strings.Join(reported, ",")
+		// 所有分段都已提交并投递
+		refs := splitList(record.MessageRefs)
+		if len(refs) < record.Segments {
+			return true
+		}
+		for _, ref := range refs {
+			if !slices.Contains(reported, ref) {
+				return true
+			}
+		}
+		record.Status = models.SmsStatusDelivered
+		record.Error = ""
+	case st < 0x40:
+		record.Error = fmt.Sprintf("delivery pending, status 0x%02x", st)
+		return true
+	default:
+		record.Status = models.SmsStatusUndeliverable
+		record.Error = fmt.Sprintf("delivery failed, status 0x%02x", st)
+	}
+	if dt.IsZero() {
+		dt = time.Now()
+	}
+	record.DeliveredAt = &dt
+	return true
+}
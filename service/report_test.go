@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rehiy/web-modem/models"
+)
+
+func TestUpdateReported(t *testing.T) {
+	dt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sent := func(refs string, segments int) *models.Sms {
+		return &models.Sms{Status: models.SmsStatusSent, MessageRefs: refs, Segments: segments}
+	}
+
+	cases := []struct {
+		name      string
+		record    *models.Sms
+		mr        int
+		st        byte
+		status    string
+		delivered bool
+	}{
+		{"delivered", sent("1", 1), 1, 0x00, models.SmsStatusDelivered, true},
+		{"replaced", sent("1", 1), 1, 0x02, models.SmsStatusDelivered, true},
+		{"pending", sent("1", 1), 1, 0x20, models.SmsStatusSent, false},
+		{"pending end", sent("1", 1), 1, 0x3F, models.SmsStatusSent, false},
+		{"failed", sent("1", 1), 1, 0x40, models.SmsStatusUndeliverable, true},
+		{"expired", sent("1", 1), 1, 0x46, models.SmsStatusUndeliverable, true},
+		{"first segment", sent("1,2", 2), 1, 0x00, models.SmsStatusSent, false},
+		{"partial submit", sent("1", 2), 1, 0x00, models.SmsStatusSent, false},
+		{"segment failed", sent("1,2", 2), 2, 0x41, models.SmsStatusUndeliverable, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if !updateReported(c.record, c.mr, c.st, dt) {
+				t.Fatal("record not changed")
+			}
+			if c.record.Status != c.status {
+				t.Errorf("status = %s, want %s", c.record.Status, c.status)
+			}
+			if got := c.record.DeliveredAt != nil; got != c.delivered {
+				t.Errorf("delivered_at set = %v, want %v", got, c.delivered)
+			}
+		})
+	}
+}
+
+func TestUpdateReportedSegments(t *testing.T) {
+	record := &models.Sms{Status: models.SmsStatusSent, MessageRefs: "7,8,9", Segments: 3}
+	for _, mr := range []int{9, 7, 7} {
+		updateReported(record, mr, 0x00, time.Time{})
+		if record.Status != models.SmsStatusSent {
+			t.Fatalf("status = %s after mr %d, want sent", record.Status, mr)
+		}
+	}
+	if record.Reported != "9,7" {
+		t.Errorf("reported = %s, want 9,7", record.Reported)
+	}
+
+	updateReported(record, 8, 0x00, time.Time{})
+	if record.Status != models.SmsStatusDelivered || record.DeliveredAt == nil {
+		t.Errorf("status = %s, want delivered", record.Status)
+	}
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rehiy/modem/at"
+
+	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/models"
+)
+
+// networkRefresh 刷新设备运营商和信号强度的间隔
+var networkRefresh = time.Minute
+
+// routeModem 可用于发送短信的设备
+type routeModem struct {
+	name, number, iccid string
+	operator            string
+	signal              int // rssi，99 表示未知
+}
+
+// smsRouter 按路由规则和策略为收件人选择设备
+type smsRouter struct {
+	ms     *ModemService
+	policy string
+	modems []routeModem      // 已连接的设备，按名称排序
+	routes []models.SmsRoute // 启用的路由规则，设备名称已解析
+	loads  map[string]int    // 每台设备待发送的任务数量
+}
+
+// IsRoutePolicy 判断是否为支持的路由策略
+func IsRoutePolicy(policy string) bool {
+	switch policy {
+	case models.RoutePolicyRoundRobin, models.RoutePolicyLeastLoaded, models.RoutePolicyBestSignal, models.RoutePolicySticky:
+		return true
+	}
+	return false
+}
+
+// newRouter 读取当前的设备状态和路由规则
+func (m *ModemService) newRouter() (*smsRouter, error) {
+	routes, err := database.GetEnabledSmsRoutes()
+	if err != nil {
+		return nil, err
+	}
+	r := &smsRouter{ms: m, policy: database.GetSmsRoutePolicy(), routes: routes, loads: map[string]int{}}
+
+	m.mu.Lock()
+	for _, conn := range m.pool {
+		if conn.Connected && conn.Device != nil {
+			r.modems = append(r.modems, routeModem{conn.Name, conn.Number, conn.ICCID, conn.Operator, conn.Signal})
+		}
+	}
+	for i, route := range r.routes {
+		if conn := m.lookupConn(route.ModemName); conn != nil {
+			r.routes[i].ModemName = conn.Name
+		}
+	}
+	m.mu.Unlock()
+	sort.Slice(r.modems, func(i, j int) bool { return r.modems[i].name < r.modems[j].name })
+
+	if r.policy == models.RoutePolicyLeastLoaded {
+		if r.loads, err = database.CountActiveSmsJobs(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// pick 为收件人选择设备，exclude 中的设备不参与选择
+func (r *smsRouter) pick(number string, exclude []string) (*routeModem, error) {
+	list := r.eligible(number, exclude)
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no modem available for %s", number)
+	}
+
+	// 从轮转位置开始排列，策略相同时依次使用
+	start := int(r.ms.routeSeq.Add(1) % uint64(len(list)))
+	list = append(list[start:], list[:start]...)
+
+	best := &list[0]
+	switch r.policy {
+	case models.RoutePolicyLeastLoaded:
+		for i := range list {
+			if r.loads[list[i].name] < r.loads[best.name] {
+				best = &list[i]
+			}
+		}
+	case models.RoutePolicyBestSignal:
+		for i := range list {
+			if signalLevel(list[i].signal) > signalLevel(best.signal) {
+				best = &list[i]
+			}
+		}
+	case models.RoutePolicySticky:
+		last, err := database.LastSentModem(number)
+		if err != nil {
+			log.Printf("[Router] %v", err)
+		}
+		for i := range list {
+			if list[i].name == last {
+				best = &list[i]
+			}
+		}
+	}
+
+	r.loads[best.name]++
+	return best, nil
+}
+
+// eligible 返回可以发送给该号码的设备，号码匹配路由规则时只使用最长前缀规则指定的设备
+func (r *smsRouter) eligible(number string, exclude []string) []routeModem {
+	number = trimPlus(number)
+	var matched []models.SmsRoute
+	longest := -1
+	for _, route := range r.routes {
+		prefix := trimPlus(route.Prefix)
+		if !strings.HasPrefix(number, prefix) || len(prefix) < longest {
+			continue
+		}
+		if len(prefix) > longest {
+			matched, longest = nil, len(prefix)
+		}
+		matched = append(matched, route)
+	}
+
+	var list []routeModem
+	for _, modem := range r.modems {
+		if slices.Contains(exclude, modem.name) {
+			continue
+		}
+		ok := len(matched) == 0
+		for _, route := range matched {
+			if route.ModemName != "" && route.ModemName != modem.name {
+				continue
+			}
+			if route.Operator != "" && !strings.Contains(strings.ToLower(modem.operator), strings.ToLower(route.Operator)) {
+				continue
+			}
+			ok = true
+			break
+		}
+		if ok {
+			list = append(list, modem)
+		}
+	}
+	return list
+}
+
+// failover 将发送失败的任务转到其他可用设备，没有可用设备时返回 false
+func (m *ModemService) failover(job *models.SmsJob, record *models.Sms, cause error) bool {
+	router, err := m.newRouter()
+	if err != nil {
+		log.Printf("[%s] %v", job.ModemName, err)
+		return false
+	}
+	tried := append(splitList(job.Tried), job.ModemName)
+	next, err := router.pick(job.Number, tried)
+	if err != nil {
+		return false
+	}
+
+	log.Printf("[%s] sms job %d failed, fail over to %s: %v", job.ModemName, job.ID, next.name, cause)
+	job.Tried = strings.Join(tried, ",")
+	job.Status, job.LastError, job.NextRunAt = models.SmsJobPending, cause.Error(), time.Now()
+	record.Status, record.Error = models.SmsStatusQueued, cause.Error()
+	m.reassignJob(job, record, next, models.SmsJobSending, "next_run_at", "tried")
+	return true
+}
+
+// rerouteJobs 将已断开设备上到期的自动路由任务转到其他可用设备
+func (m *ModemService) rerouteJobs(name string) {
+	jobs, err := database.DueAutoRouteSmsJobs(name, time.Now(), 100)
+	if err != nil || len(jobs) == 0 {
+		return
+	}
+	router, err := m.newRouter()
+	if err != nil {
+		log.Printf("[%s] %v", name, err)
+		return
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+		next, err := router.pick(job.Number, append(splitList(job.Tried), name))
+		if err != nil {
+			continue
+		}
+		log.Printf("[%s] disconnected, sms job %d rerouted to %s", name, job.ID, next.name)
+		m.reassignJob(job, &models.Sms{ID: job.SmsID}, next, models.SmsJobPending)
+	}
+}
+
+// reassignJob 在任务仍处于 from 状态时修改任务及发送记录使用的设备并保存 columns 指定的字段，唤醒该设备的发送队列
+func (m *ModemService) reassignJob(job *models.SmsJob, record *models.Sms, to *routeModem, from string, columns ...string) {
+	job.ModemName, record.ModemName = to.name, to.name
+	if !m.saveJob(job, from, append(columns, "modem_name")...) {
+		return
+	}
+	if err := database.UpdateSmsSender(record.ID, to.name, to.number, to.iccid); err != nil {
+		log.Printf("[%s] %v", to.name, err)
+	}
+	heldByCampaign(job, record)
+	if record.Status != "" {
+		updateOutbound(record)
+	}
+	m.wakeModem(to.name)
+}
+
+// wakeModem 唤醒设备的发送队列
+func (m *ModemService) wakeModem(name string) {
+	m.mu.Lock()
+	conn := m.pool[name]
+	m.mu.Unlock()
+	if conn != nil {
+		conn.wakeSender()
+	}
+}
+
+// refreshNetwork 刷新设备的运营商和信号强度，供路由选择设备
+func (m *ModemService) refreshNetwork(conn *ModemConn, dev *at.Device) {
+	operator, signal := "", 99
+	err := conn.Exec(context.Background(), PriorityQuery, "AT+CSQ", func() error {
+		if _, _, op, _, err := dev.GetOperator(); err == nil {
+			operator = op
+		}
+		rssi, _, err := dev.GetSignalQuality()
+		if err == nil {
+			signal = rssi
+		}
+		return err
+	})
+	if err != nil {
+		log.Printf("[%s] failed to refresh network: %v", conn.Name, err)
+	}
+
+	m.mu.Lock()
+	if conn.Device == dev {
+		conn.Operator, conn.Signal, conn.netAt = operator, signal, time.Now()
+	}
+	m.mu.Unlock()
+}
+
+// signalLevel 返回用于比较的信号强度，未知时最低
+func signalLevel(rssi int) int {
+	if rssi == 99 {
+		return -1
+	}
+	return rssi
+}
+
+// trimPlus 去除号码的国际前缀 +
+func trimPlus(number string) string {
+	return strings.TrimPrefix(strings.TrimSpace(number), "+")
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/rehiy/web-modem/models"
+)
+
+func testRouter(policy string, routes ...models.SmsRoute) *smsRouter {
+	return &smsRouter{
+		ms:     &ModemService{},
+		policy: policy,
+		modems: []routeModem{
+			{name: "a", operator: "China Mobile", signal: 10},
+			{name: "b", operator: "China Unicom", signal: 25},
+			{name: "c", operator: "China Mobile", signal: 99},
+		},
+		routes: routes,
+		loads:  map[string]int{"a": 3, "b": 1, "c": 2},
+	}
+}
+
+func routeNames(list []routeModem) []string {
+	names := []string{}
+	for _, m := range list {
+		names = append(names, m.name)
+	}
+	return names
+}
+
+func TestRouterEligible(t *testing.T) {
+	r := testRouter(models.RoutePolicyRoundRobin,
+		models.SmsRoute{Prefix: "+86", Operator: "mobile"},
+		models.SmsRoute{Prefix: "86186", ModemName: "b"},
+		models.SmsRoute{Prefix: "86186", ModemName: "c"},
+	)
+
+	cases := []struct {
+		number  string
+		exclude []string
+		want    []string
+	}{
+		{"+8613800000000", nil, []string{"a", "c"}},
+		{"+8613800000000", []string{"a"}, []string{"c"}},
+		{"+8618600000000", nil, []string{"b", "c"}}, // 最长前缀优先
+		{"+8618600000000", []string{"b", "c"}, []string{}},
+		{"+4412345678", nil, []string{"a", "b", "c"}}, // 没有匹配的规则
+	}
+	for _, c := range cases {
+		if got := routeNames(r.eligible(c.number, c.exclude)); !slices.Equal(got, c.want) {
+			t.Errorf("eligible(%s, %v) = %v, want %v", c.number, c.exclude, got, c.want)
+		}
+	}
+}
+
+func TestRouterPick(t *testing.T) {
+	r := testRouter(models.RoutePolicyBestSignal)
+	if m, _ := r.pick("10086", nil); m.name != "b" {
+		t.Errorf("best signal picked %s, want b", m.name)
+	}
+	if m, _ := r.pick("10086", []string{"b"}); m.name != "a" {
+		t.Errorf("best signal without b picked %s, want a", m.name)
+	}
+
+	// 选中后计入负载
+	r = testRouter(models.RoutePolicyLeastLoaded)
+	picked := []string{}
+	for range 4 {
+		m, err := r.pick("10086", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		picked = append(picked, m.name)
+	}
+	if picked[0] != "b" || slices.Contains(picked[:3], "a") {
+		t.Errorf("least loaded picked %v, loads %v", picked, r.loads)
+	}
+
+	r = testRouter(models.RoutePolicyRoundRobin)
+	seen := map[string]bool{}
+	for range 3 {
+		m, _ := r.pick("10086", nil)
+		seen[m.name] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("round robin picked %v, want all modems", seen)
+	}
+
+	if _, err := r.pick("10086", []string{"a", "b", "c"}); err == nil {
+		t.Error("pick without modems should fail")
+	}
+}
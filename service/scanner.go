@@ -0,0 +1,148 @@
+package service
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"sync"
+	"time"
+)
+
+var (
+	scanWorkers  = 8  // 并行探测的端口数
+	scanJobLimit = 20 // 保留的扫描任务数
+)
+
+// ScanJob 后台扫描任务
+type ScanJob struct {
+	ID         string            `json:"id"`
+	Status     string            `json:"status"` // running, done
+	Ports      map[string]string `json:"ports"`  // 各端口进度：opening, at ok, failed: 原因
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// ScanModems 在后台扫描可用的调制解调器并连接到它们，立即返回扫描任务
+func (m *ModemService) ScanModems(devs ...string) *ScanJob {
+	m.mu.Lock()
+	ports := []string{}
+	for _, u := range m.findPorts(devs...) {
+		if !m.ignored[u] {
+			ports = append(ports, u)
+		}
+	}
+	m.mu.Unlock()
+
+	job := &ScanJob{
+		ID:        fmt.Sprintf("%x", time.Now().UnixNano()),
+		Status:    "running",
+		Ports:     map[string]string{},
+		StartedAt: time.Now(),
+	}
+	for _, u := range ports {
+		job.Ports[u] = "pending"
+	}
+
+	m.scanMu.Lock()
+	m.scans[job.ID] = job
+	m.pruneScans()
+	res := copyScanJob(job)
+	m.scanMu.Unlock()
+
+	go func() {
+		emitEvent(job.ID, "scan_started", ports)
+		m.connectPorts(ports, func(u, status string) {
+			m.scanMu.Lock()
+			job.Ports[u] = status
+			m.scanMu.Unlock()
+			emitEvent(path.Base(u), "scan", job.ID+" "+status)
+		})
+
+		m.scanMu.Lock()
+		for u, status := range job.Ports {
+			if status == "pending" {
+				job.Ports[u] = "skipped" // 同一设备的其他接口
+			}
+		}
+		now := time.Now()
+		job.Status = "done"
+		job.FinishedAt = &now
+		m.scanMu.Unlock()
+		emitEvent(job.ID, "scan_done", len(ports))
+	}()
+
+	return res
+}
+
+// GetScanJob 返回扫描任务的进度
+func (m *ModemService) GetScanJob(id string) (*ScanJob, error) {
+	m.scanMu.Lock()
+	defer m.scanMu.Unlock()
+
+	job, ok := m.scans[id]
+	if !ok {
+		return nil, fmt.Errorf("scan job %s not found", id)
+	}
+	return copyScanJob(job), nil
+}
+
+// connectPorts 按 USB 设备分组并行连接端口，每组依次尝试直到找到 AT 接口，返回已尝试端口的连接结果
+func (m *ModemService) connectPorts(ports []string, progress func(u, status string)) map[string]error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, scanWorkers)
+	errs := map[string]error{}
+
+	infos := readUSBInfos(ports)
+	m.mu.Lock()
+	groups := m.groupPorts(ports, infos)
+	m.mu.Unlock()
+
+	for _, group := range groups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			for _, u := range group {
+				var report func(string)
+				if progress != nil {
+					report = func(s string) { progress(u, s) }
+				}
+				err := m.makeConnect(u, report)
+
+				mu.Lock()
+				errs[u] = err
+				mu.Unlock()
+
+				if err == nil {
+					break
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	return errs
+}
+
+// pruneScans 清理过期的扫描任务，调用方需持有 scanMu
+func (m *ModemService) pruneScans() {
+	for len(m.scans) > scanJobLimit {
+		var oldest *ScanJob
+		for _, job := range m.scans {
+			if oldest == nil || job.StartedAt.Before(oldest.StartedAt) {
+				oldest = job
+			}
+		}
+		delete(m.scans, oldest.ID)
+	}
+}
+
+// copyScanJob 返回扫描任务副本，调用方需持有 scanMu
+func copyScanJob(job *ScanJob) *ScanJob {
+	res := *job
+	res.Ports = maps.Clone(job.Ports)
+	return &res
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rehiy/modem/at"
+	"github.com/rehiy/modem/sms"
+	"github.com/rehiy/modem/sms/pdumode"
+	"github.com/rehiy/modem/sms/tpdu"
+
+	"github.com/rehiy/web-modem/models"
+)
+
+// submitTimeout 提交 PDU 后等待 +CMGS 的时间
+var submitTimeout = time.Minute
+
+// submitResult 短信中心对单个分段的提交结果
+type submitResult struct {
+	mr  int
+	err error
+}
+
+// encodeSubmit 将短信编码为 SMS-SUBMIT TPDU，长短信拆分为多个分段，srr 为是否请求状态报告
+func encodeSubmit(number, text string, srr bool, opts models.SubmitOptions) ([][]byte, error) {
+	tpdus, err := sms.Encode([]byte(text), sms.To(number))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sms: %w", err)
+	}
+
+	pdus := [][]byte{}
+	for _, t := range tpdus {
+		if srr {
+			t.FirstOctet |= tpdu.FoSRR
+		}
+		if opts.RejectDuplicates {
+			t.FirstOctet |= tpdu.FoRD
+		}
+		if opts.MessageClass != nil {
+			dcs, err := t.DCS.WithClass(tpdu.MessageClass(*opts.MessageClass))
+			if err != nil {
+				return nil, fmt.Errorf("failed to set message class: %w", err)
+			}
+			t.SetDCS(byte(dcs))
+		}
+		if opts.PID != 0 {
+			t.SetPID(byte(opts.PID))
+		}
+		switch {
+		case opts.ValidUntil != nil:
+			vp := tpdu.ValidityPeriod{}
+			vp.SetAbsolute(tpdu.Timestamp{Time: *opts.ValidUntil})
+			t.SetVP(vp)
+		case opts.ValidityPeriod > 0:
+			vp := tpdu.ValidityPeriod{}
+			vp.SetRelative(time.Duration(opts.ValidityPeriod) * time.Second)
+			t.SetVP(vp)
+		}
+		b, err := t.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal tpdu: %w", err)
+		}
+		pdus = append(pdus, b)
+	}
+	return pdus, nil
+}
+
+// submitPdus 从第 from 个分段（从 0 开始）依次提交，返回短信中心分配的消息参考号，需在命令队列中执行
+func (c *ModemConn) submitPdus(pdus [][]byte, from int) ([]int, error) {
+	refs := []int{}
+	for i := from; i < len(pdus); i++ {
+		b := pdus[i]
+		pdu := pdumode.PDU{TPDU: b}
+		hex, err := pdu.MarshalHexString()
+		if err != nil {
+			return refs, fmt.Errorf("failed to marshal pdu: %w", err)
+		}
+
+		// 丢弃之前残留的提交结果
+		for len(c.submits) > 0 {
+			<-c.submits
+		}
+
+		// 等待提示符 >，未收到时不写入 PDU
+		if err := c.waitPrompt(len(b)); err != nil {
+			return refs, fmt.Errorf("segment %d: %w", i+1, err)
+		}
+		// 短信中心响应较慢，PDU 提交后的超时忽略，结果由 +CMGS 通知返回
+		resp, err := c.SendCommand(hex + "\x1A")
+		if err != nil && strings.Contains(err.Error(), "timeout") {
+			err = nil
+		}
+		if err = commandError(resp, err); err != nil {
+			return refs, fmt.Errorf("segment %d: %w", i+1, err)
+		}
+
+		// +CMGS 由 URC 处理函数转发
+		select {
+		case res := <-c.submits:
+			if res.err != nil {
+				return refs, fmt.Errorf("segment %d: %w", i+1, res.err)
+			}
+			refs = append(refs, res.mr)
+		case <-time.After(submitTimeout):
+			return refs, fmt.Errorf("segment %d: wait for +CMGS timeout", i+1)
+		}
+	}
+	return refs, nil
+}
+
+// waitPrompt 发送 AT+CMGS 并等待短信输入提示符 >，超时或返回错误时取消输入
+func (c *ModemConn) waitPrompt(length int) error {
+	resp, err := c.SendCommand(fmt.Sprintf("AT+CMGS=%d\r", length))
+	err = commandError(resp, err)
+	if err == nil && !slices.Contains(resp, ">") {
+		err = fmt.Errorf("unexpected response: %v", resp)
+	}
+	if err != nil {
+		// 设备可能仍在等待 PDU，发送 ESC 取消
+		c.SendCommand("\x1B")
+		return fmt.Errorf("wait for prompt: %w", err)
+	}
+	return nil
+}
+
+// responseSet 返回命令的最终响应集合，提示符 > 由 pduPort 补全为一行，作为 AT+CMGS 的最终响应
+func responseSet() *at.ResponseSet {
+	return at.DefaultResponseSet()
+}
+
+// commandError 检查命令响应中的错误
+func commandError(resp []string, err error) error {
+	if err != nil {
+		return err
+	}
+	for _, line := range resp {
+		if strings.HasPrefix(line, "ERROR") || strings.HasPrefix(line, "+CMS ERROR") || strings.HasPrefix(line, "+CME ERROR") {
+			return fmt.Errorf("%s", line)
+		}
+	}
+	return nil
+}
+
+// notifySubmit 将 +CMGS 或 +CMS ERROR 通知转发给正在发送的短信
+func (m *ModemService) notifySubmit(u, e string, p map[int]string) {
+	m.mu.Lock()
+	conn := m.connByPort(u)
+	m.mu.Unlock()
+	if conn == nil || conn.submits == nil {
+		return
+	}
+
+	res := submitResult{}
+	if e == "+CMGS" {
+		if res.mr, res.err = strconv.Atoi(p[0]); res.err != nil {
+			res.err = fmt.Errorf("invalid +CMGS: %s", p[0])
+		}
+	} else {
+		res.err = fmt.Errorf("%s: %s", e, p[0])
+	}
+
+	select {
+	case conn.submits <- res:
+	default:
+	}
+}
@@ -0,0 +1,446 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/models"
+)
+
+// 发送队列参数
+var (
+	senderPoll       = 5 * time.Second  // 检查待发送任务的间隔
+	smsMaxAttempts   = 5                // 最多发送次数
+	smsRetryBase     = 30 * time.Second // 首次重试间隔，之后每次翻倍
+	smsRetryMaxDelay = 30 * time.Minute
+	scheduleGrace    = time.Minute // 定时发送允许的延迟，超过后按 LatePolicy 处理
+)
+
+// maxValidityPeriod 相对有效期的最大值（63 周）
+const maxValidityPeriod = 63 * 7 * 24 * time.Hour
+
+// SendOptions 短信发送选项
+type SendOptions struct {
+	SendAt        *time.Time // 定时发送时间，为空时立即发送
+	LatePolicy    string     // 错过定时发送时间的处理方式，默认延迟发送
+	Transliterate bool       // 将非 GSM-7 字符替换为相近的 GSM-7 字符，减少分段
+	Submit        models.SubmitOptions
+}
+
+// check 检查发送选项并填充默认值
+func (o *SendOptions) check() error {
+	switch o.LatePolicy {
+	case "":
+		o.LatePolicy = models.LatePolicySend
+	case models.LatePolicySend, models.LatePolicyExpire:
+	default:
+		return fmt.Errorf("invalid late_policy: %s", o.LatePolicy)
+	}
+	if o.SendAt != nil && time.Since(*o.SendAt) > scheduleGrace {
+		return fmt.Errorf("send_at is in the past")
+	}
+
+	sub := o.Submit
+	if sub.MessageClass != nil && (*sub.MessageClass < 0 || *sub.MessageClass > 3) {
+		return fmt.Errorf("message_class must be 0-3")
+	}
+	if sub.PID < 0 || sub.PID > 0xFF {
+		return fmt.Errorf("pid must be 0-255")
+	}
+	if sub.ValidityPeriod < 0 || time.Duration(sub.ValidityPeriod)*time.Second > maxValidityPeriod {
+		return fmt.Errorf("validity_period must be 0-%d seconds", int(maxValidityPeriod/time.Second))
+	}
+	if sub.ValidUntil != nil {
+		if sub.ValidityPeriod > 0 {
+			return fmt.Errorf("validity_period and valid_until are mutually exclusive")
+		}
+		if !sub.ValidUntil.After(time.Now()) {
+			return fmt.Errorf("valid_until is in the past")
+		}
+	}
+	return nil
+}
+
+// transientCmsErrors 可重试的 +CMS ERROR 错误码（3GPP TS 27.005、TS 24.011）
+var transientCmsErrors = []int{
+	27,  // 目标不可达
+	38,  // 网络故障
+	41,  // 临时故障
+	42,  // 网络拥塞
+	47,  // 资源不可用
+	98,  // 消息类型与协议状态不符
+	111, // 协议错误
+	127, // 网络互通错误
+	314, // SIM 卡忙
+	331, // 无网络服务
+	332, // 网络超时
+	500, // 未知错误
+}
+
+// transientSmsErrors 可重试的发送错误：命令或 +CMGS 超时、等待命令队列超时、设备断开或关闭
+var transientSmsErrors = []string{
+	"timeout",
+	"deadline exceeded",
+	"device closed",
+	"not connected",
+	"command queue stopped",
+	"failed to write",
+	"incomplete: wrote",
+}
+
+// cmsErrorCode 匹配 +CMS ERROR 错误码
+var cmsErrorCode = regexp.MustCompile(`\+CMS ERROR:\s*(\d+)`)
+
+// EnqueueSms 将短信加入设备的发送队列，返回短信任务，u 为空时按路由策略选择设备
+func (m *ModemService) EnqueueSms(u, number, text string, opts SendOptions) (*models.SmsJob, error) {
+	if number == "" || text == "" {
+		return nil, fmt.Errorf("number and message are required")
+	}
+	if err := opts.check(); err != nil {
+		return nil, err
+	}
+	if opts.Transliterate {
+		text = TransliterateGsm(text)
+	}
+	if database.GetDB() == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+
+	// 未指定设备时按路由策略选择
+	var sender *routeModem
+	if u == "" {
+		router, err := m.newRouter()
+		if err != nil {
+			return nil, err
+		}
+		if sender, err = router.pick(number, nil); err != nil {
+			return nil, err
+		}
+	} else {
+		m.mu.Lock()
+		if conn := m.lookupConn(u); conn != nil {
+			sender = &routeModem{name: conn.Name, number: conn.Number, iccid: conn.ICCID}
+		}
+		m.mu.Unlock()
+		if sender == nil {
+			return nil, fmt.Errorf("[%s] not found", u)
+		}
+	}
+
+	pdus, err := encodeSubmit(number, text, false, opts.Submit)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	record := &models.Sms{
+		Content:       text,
+		ReceiveTime:   now,
+		ReceiveNumber: number,
+		SendNumber:    sender.number,
+		Direction:     "out",
+		ModemName:     sender.name,
+		ICCID:         sender.iccid,
+		Segments:      len(pdus),
+		Status:        models.SmsStatusQueued,
+	}
+	job := &models.SmsJob{
+		ModemName:  sender.name,
+		Number:     number,
+		Content:    text,
+		Status:     models.SmsJobPending,
+		NextRunAt:  now,
+		SendAt:     opts.SendAt,
+		LatePolicy: opts.LatePolicy,
+		AutoRoute:  u == "",
+
+		SubmitOptions: opts.Submit,
+	}
+	if opts.SendAt != nil {
+		job.NextRunAt = *opts.SendAt
+	}
+	if err := database.CreateSmsJob(record, job); err != nil {
+		return nil, err
+	}
+
+	emitEvent(sender.name, "sms_job", fmt.Sprintf("%d %s", job.ID, job.Status))
+	m.wakeModem(sender.name)
+	return job, nil
+}
+
+// RescheduleSmsJob 修改等待中的短信任务的定时发送时间
+func (m *ModemService) RescheduleSmsJob(id int, sendAt time.Time, latePolicy string) (*models.SmsJob, error) {
+	opts := SendOptions{SendAt: &sendAt, LatePolicy: latePolicy}
+	if err := opts.check(); err != nil {
+		return nil, err
+	}
+
+	job, err := database.RescheduleSmsJob(id, sendAt, latePolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	m.wakeModem(job.ModemName)
+	emitEvent(job.ModemName, "sms_job", fmt.Sprintf("%d %s", job.ID, job.Status))
+	return job, nil
+}
+
+// CancelSmsJob 取消等待中的短信任务，群发任务中最后一条短信取消后群发任务标记为已完成
+func (m *ModemService) CancelSmsJob(id int) (*models.SmsJob, error) {
+	job, err := database.CancelSmsJob(id)
+	if err != nil {
+		return nil, err
+	}
+
+	emitEvent(job.ModemName, "sms_job", fmt.Sprintf("%d %s", job.ID, job.Status))
+	m.finishJob(job)
+	return job, nil
+}
+
+// RecoverSmsJobs 恢复上次退出时中断的发送任务，过期错过定时发送时间的任务
+func (m *ModemService) RecoverSmsJobs() {
+	n, err := database.ResetSendingSmsJobs()
+	if err != nil {
+		log.Printf("[Sender] %v", err)
+	} else if n > 0 {
+		log.Printf("[Sender] %d interrupted sms jobs will be resent", n)
+	}
+
+	n, err = database.ExpireSmsJobs(time.Now().Add(-scheduleGrace))
+	if err != nil {
+		log.Printf("[Sender] %v", err)
+	} else if n > 0 {
+		log.Printf("[Sender] %d scheduled sms jobs expired", n)
+	}
+}
+
+// wakeSender 唤醒发送队列
+func (c *ModemConn) wakeSender() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runSender 依次发送设备队列中的短信，直到连接被移除
+func (m *ModemService) runSender(conn *ModemConn) {
+	var window []time.Time // 最近一分钟的发送时间
+	for {
+		wait := m.sendNext(conn, &window)
+		select {
+		case <-conn.stop:
+			return
+		case <-conn.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// sendNext 发送一条到期的短信任务，返回下次检查前的等待时间
+func (m *ModemService) sendNext(conn *ModemConn, window *[]time.Time) time.Duration {
+	if database.GetDB() == nil {
+		return senderPoll
+	}
+
+	m.mu.Lock()
+	name, connected := conn.Name, conn.Connected && conn.Device != nil
+	m.mu.Unlock()
+	if !connected {
+		m.rerouteJobs(name)
+		return senderPoll
+	}
+
+	// 限速
+	now := time.Now()
+	for len(*window) > 0 && now.Sub((*window)[0]) >= time.Minute {
+		*window = (*window)[1:]
+	}
+	if limit := database.GetSmsRateLimit(); limit > 0 && len(*window) >= limit {
+		return (*window)[0].Add(time.Minute).Sub(now)
+	}
+
+	job, err := database.NextSmsJob(name, now)
+	if err != nil {
+		log.Printf("[%s] %v", name, err)
+		return senderPoll
+	}
+	if job == nil {
+		// 等待下一条定时任务
+		next, err := database.NextSmsJobTime(name)
+		if err != nil || next == nil {
+			return senderPoll
+		}
+		return min(max(time.Until(*next), 0), senderPoll)
+	}
+
+	// 错过定时发送时间
+	if job.SendAt != nil && job.Attempts == 0 && job.LatePolicy == models.LatePolicyExpire && now.Sub(*job.SendAt) > scheduleGrace {
+		m.expireJob(job, "missed scheduled time")
+		return 0
+	}
+
+	// 超过绝对有效期，短信中心不会再投递
+	if job.ValidUntil != nil && now.After(*job.ValidUntil) {
+		m.expireJob(job, "validity period elapsed")
+		return 0
+	}
+
+	*window = append(*window, now)
+	m.sendJob(conn, job)
+	return 0
+}
+
+// sendJob 发送短信任务，自动路由的任务失败时先切换到其他设备，可重试的错误按指数退避重新排队；
+// 长短信部分分段已提交时，重试从未提交的分段继续
+func (m *ModemService) sendJob(conn *ModemConn, job *models.SmsJob) {
+	// 任务可能已被取消或改期，认领后使用数据库中的最新内容
+	claimed, err := database.ClaimSmsJob(job, time.Now())
+	if err != nil {
+		log.Printf("[%s] %v", job.ModemName, err)
+		return
+	}
+	if !claimed {
+		log.Printf("[%s] sms job %d is no longer pending, skipped", job.ModemName, job.ID)
+		return
+	}
+	emitEvent(job.ModemName, "sms_job", fmt.Sprintf("%d %s", job.ID, job.Status))
+
+	record := &models.Sms{ID: job.SmsID, ModemName: job.ModemName, MessageRefs: job.Submitted}
+	record.Status = models.SmsStatusSending
+	updateOutbound(record)
+
+	srr := database.IsStatusReportEnabled()
+	if job.StatusReport != nil {
+		srr = *job.StatusReport
+	}
+
+	// 编码结果是确定的（长短信参考号每次从 1 开始），已提交的分段可以跳过
+	refs := splitInts(job.Submitted)
+	pdus, err := encodeSubmit(job.Number, job.Content, srr, job.SubmitOptions)
+	if err == nil && len(refs) < len(pdus) {
+		err = conn.Exec(context.Background(), PrioritySend, "AT+CMGS", func() error {
+			sent, err := conn.submitPdus(pdus, len(refs))
+			refs = append(refs, sent...)
+			return err
+		})
+	}
+
+	job.Submitted = database.IntArrayToString(refs)
+	record.MessageRefs = job.Submitted
+	// 部分分段已提交时不切换设备，否则收件人无法合并长短信
+	if err != nil && len(refs) == 0 && job.AutoRoute && job.Attempts < smsMaxAttempts && m.failover(job, record, err) {
+		return
+	}
+
+	columns := []string{"submitted"}
+	switch {
+	case err == nil:
+		now := time.Now()
+		job.Status, job.LastError = models.SmsJobSent, ""
+		record.Status, record.Error, record.SentAt = models.SmsStatusSent, "", &now
+		log.Printf("[%s] sms job %d sent to %s, refs: %s", job.ModemName, job.ID, job.Number, record.MessageRefs)
+	case isTransientSmsError(err) && job.Attempts < smsMaxAttempts:
+		delay := min(smsRetryBase<<(job.Attempts-1), smsRetryMaxDelay)
+		job.Status, job.LastError, job.NextRunAt = models.SmsJobPending, err.Error(), time.Now().Add(delay)
+		job.Tried = "" // 重试时可以再次切换到其他设备
+		columns = append(columns, "next_run_at", "tried")
+		record.Status, record.Error = models.SmsStatusQueued, err.Error()
+		log.Printf("[%s] sms job %d failed, retry in %v: %v", job.ModemName, job.ID, delay, err)
+	default:
+		job.Status, job.LastError = models.SmsJobFailed, err.Error()
+		record.Status, record.Error = models.SmsStatusFailed, err.Error()
+		log.Printf("[%s] sms job %d failed: %v", job.ModemName, job.ID, err)
+	}
+
+	if m.saveJob(job, models.SmsJobSending, columns...) {
+		heldByCampaign(job, record)
+		updateOutbound(record)
+		m.finishJob(job)
+	}
+}
+
+// heldByCampaign 重新排队的任务因所属群发任务已暂停或取消而未能排队时，同步发送记录的状态
+func heldByCampaign(job *models.SmsJob, record *models.Sms) {
+	switch job.Status {
+	case models.SmsJobPaused:
+		log.Printf("[%s] sms job %d held by paused campaign %d", job.ModemName, job.ID, job.CampaignID)
+	case models.SmsJobCancelled:
+		record.Status = models.SmsStatusCancelled
+		log.Printf("[%s] sms job %d cancelled with campaign %d", job.ModemName, job.ID, job.CampaignID)
+	}
+}
+
+// finishJob 群发任务中的短信处理完成后检查群发任务是否已完成
+func (m *ModemService) finishJob(job *models.SmsJob) {
+	if job.CampaignID != 0 && job.Status != models.SmsJobPending && job.Status != models.SmsJobPaused {
+		(&CampaignService{ms: m}).finishJob(job.CampaignID)
+	}
+}
+
+// expireJob 将错过定时发送时间或超过有效期的任务标记为过期
+func (m *ModemService) expireJob(job *models.SmsJob, reason string) {
+	job.Status = models.SmsJobExpired
+	job.LastError = reason
+	if !m.saveJob(job, models.SmsJobPending) {
+		return
+	}
+	log.Printf("[%s] sms job %d expired: %s", job.ModemName, job.ID, reason)
+	updateOutbound(&models.Sms{ID: job.SmsID, ModemName: job.ModemName, Status: models.SmsStatusExpired, Error: job.LastError, MessageRefs: job.Submitted})
+	m.finishJob(job)
+}
+
+// saveJob 在任务仍处于 from 状态时保存状态、错误信息及 columns 指定的字段并发布事件，
+// 待发送的任务还需仍已到期，任务已被取消或改期时不保存并返回 false
+func (m *ModemService) saveJob(job *models.SmsJob, from string, columns ...string) bool {
+	columns = append([]string{"status", "last_error"}, columns...)
+	var saved bool
+	var err error
+	if from == models.SmsJobPending {
+		saved, err = database.UpdateDueSmsJob(job, time.Now(), columns...)
+	} else {
+		saved, err = database.UpdateSmsJobFrom(job, from, columns...)
+	}
+	if err != nil {
+		log.Printf("[%s] %v", job.ModemName, err)
+		return false
+	}
+	if !saved {
+		log.Printf("[%s] sms job %d is no longer %s, not updated to %s", job.ModemName, job.ID, from, job.Status)
+		return false
+	}
+	emitEvent(job.ModemName, "sms_job", fmt.Sprintf("%d %s", job.ID, job.Status))
+	return true
+}
+
+// updateOutbound 保存发送记录的状态，不修改状态报告的结果
+func updateOutbound(record *models.Sms) {
+	if record.ID == 0 {
+		return
+	}
+	if err := database.UpdateSmsSendStatus(record); err != nil {
+		log.Printf("[%s] %v", record.ModemName, err)
+	}
+}
+
+// isTransientSmsError 判断发送错误是否可重试：超时、设备断开等错误及 transientCmsErrors 中的 +CMS ERROR 可重试，
+// 编码失败、设备拒绝命令等其他错误不重试
+func isTransientSmsError(err error) bool {
+	msg := err.Error()
+	if match := cmsErrorCode.FindStringSubmatch(msg); match != nil {
+		code, _ := strconv.Atoi(match[1])
+		return slices.Contains(transientCmsErrors, code)
+	}
+	for _, s := range transientSmsErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
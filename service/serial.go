@@ -0,0 +1,246 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rehiy/modem/at"
+	"github.com/tarm/serial"
+
+	"github.com/rehiy/web-modem/capture"
+	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/models"
+	"github.com/rehiy/web-modem/simulator"
+)
+
+// PortConfig 端口参数
+type PortConfig struct {
+	Baud           int           `json:"baud"`
+	DataBits       int           `json:"data_bits"`
+	Parity         string        `json:"parity"`
+	StopBits       int           `json:"stop_bits"`
+	RTSCTS         bool          `json:"rtscts"`
+	ReadTimeout    time.Duration `json:"read_timeout"`
+	CommandTimeout time.Duration `json:"command_timeout"`
+	Profile        string        `json:"profile"`     // 指定设备配置档案，为空时自动匹配
+	SmsReceive     string        `json:"sms_receive"` // 短信接收方式，见 SmsReceive*
+}
+
+// defaultPortConfig 返回默认端口参数
+func defaultPortConfig() PortConfig {
+	return PortConfig{
+		Baud:           115200,
+		DataBits:       8,
+		Parity:         "N",
+		StopBits:       1,
+		ReadTimeout:    time.Second,
+		CommandTimeout: time.Second,
+		SmsReceive:     SmsReceiveStore,
+	}
+}
+
+// splitPortSpec 拆分端口描述，例如 /dev/ttyS0?baud=9600&parity=E
+func splitPortSpec(spec string) (string, string) {
+	if i := strings.Index(spec, "?"); i >= 0 {
+		return spec[:i], spec[i+1:]
+	}
+	return spec, ""
+}
+
+// applyQuery 使用 MODEM_PORT 中的查询参数覆盖端口参数
+func (c *PortConfig) applyQuery(query string) error {
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return fmt.Errorf("invalid port params %q: %w", query, err)
+	}
+
+	for key := range q {
+		v := q.Get(key)
+		switch key {
+		case "baud":
+			c.Baud, err = strconv.Atoi(v)
+		case "data_bits":
+			c.DataBits, err = strconv.Atoi(v)
+		case "parity":
+			c.Parity = strings.ToUpper(v)
+		case "stop_bits":
+			if v == "1.5" {
+				v = "15"
+			}
+			c.StopBits, err = strconv.Atoi(v)
+		case "rtscts":
+			c.RTSCTS, err = strconv.ParseBool(v)
+		case "read_timeout":
+			c.ReadTimeout, err = time.ParseDuration(v)
+		case "command_timeout":
+			c.CommandTimeout, err = time.ParseDuration(v)
+		case "profile":
+			c.Profile = v
+		case "sms_receive":
+			c.SmsReceive = v
+		}
+		if err != nil {
+			return fmt.Errorf("invalid port param %s=%s: %w", key, v, err)
+		}
+	}
+
+	return c.validate()
+}
+
+// applySetting 使用数据库中的端口设置覆盖端口参数
+func (c *PortConfig) applySetting(s *models.PortSetting) error {
+	if s.Baud > 0 {
+		c.Baud = s.Baud
+	}
+	if s.DataBits > 0 {
+		c.DataBits = s.DataBits
+	}
+	if s.Parity != "" {
+		c.Parity = strings.ToUpper(s.Parity)
+	}
+	if s.StopBits > 0 {
+		c.StopBits = s.StopBits
+	}
+	if s.RTSCTS != nil {
+		c.RTSCTS = *s.RTSCTS
+	}
+	if s.ReadTimeout > 0 {
+		c.ReadTimeout = time.Duration(s.ReadTimeout) * time.Millisecond
+	}
+	if s.CommandTimeout > 0 {
+		c.CommandTimeout = time.Duration(s.CommandTimeout) * time.Millisecond
+	}
+	if s.Profile != "" {
+		c.Profile = s.Profile
+	}
+	if s.SmsReceive != "" {
+		c.SmsReceive = s.SmsReceive
+	}
+	return c.validate()
+}
+
+// validate 检查端口参数是否有效
+func (c *PortConfig) validate() error {
+	if c.Baud <= 0 {
+		return fmt.Errorf("invalid baud rate: %d", c.Baud)
+	}
+	if c.DataBits < 5 || c.DataBits > 8 {
+		return fmt.Errorf("invalid data bits: %d", c.DataBits)
+	}
+	if !strings.Contains("NOEMS", c.Parity) || len(c.Parity) != 1 {
+		return fmt.Errorf("invalid parity: %s", c.Parity)
+	}
+	if c.StopBits != 1 && c.StopBits != 2 && c.StopBits != 15 {
+		return fmt.Errorf("invalid stop bits: %d", c.StopBits)
+	}
+	if c.ReadTimeout < 0 || c.CommandTimeout <= 0 {
+		return fmt.Errorf("invalid timeout")
+	}
+	if c.SmsReceive != SmsReceiveStore && c.SmsReceive != SmsReceiveDirect {
+		return fmt.Errorf("invalid sms receive mode: %s", c.SmsReceive)
+	}
+	return nil
+}
+
+// CheckPortSetting 检查端口设置是否有效
+func CheckPortSetting(s *models.PortSetting) error {
+	if s.Port == "" {
+		return fmt.Errorf("port is empty")
+	}
+	c := defaultPortConfig()
+	return c.applySetting(s)
+}
+
+// portConfig 计算端口参数：默认值 < MODEM_PORT 参数 < 数据库设置
+func (m *ModemService) portConfig(u string) (PortConfig, error) {
+	c := defaultPortConfig()
+
+	m.mu.Lock()
+	query := m.params[u]
+	m.mu.Unlock()
+
+	if query != "" {
+		if err := c.applyQuery(query); err != nil {
+			return c, err
+		}
+	}
+
+	if database.GetDB() != nil {
+		if s, err := database.GetPortSetting(u); err == nil {
+			if err := c.applySetting(s); err != nil {
+				return c, err
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// isVirtualPort 判断是否为无需在文件系统中查找的端口，包括网络端口、模拟器端口和回放端口
+func isVirtualPort(u string) bool {
+	return isNetworkPort(u) || strings.HasPrefix(u, simulator.Scheme) || strings.HasPrefix(u, capture.ReplayScheme)
+}
+
+// openPort 按端口类型打开本地串口、网络端口、模拟器端口或回放端口
+func openPort(u string, c PortConfig) (at.Port, error) {
+	switch {
+	case strings.HasPrefix(u, capture.ReplayScheme):
+		port, err := capture.OpenReplay(u)
+		if err != nil {
+			return nil, err
+		}
+		return port, nil
+	case strings.HasPrefix(u, simulator.Scheme):
+		port, err := simulator.Open(u)
+		if err != nil {
+			return nil, err
+		}
+		return port, nil
+	case strings.HasPrefix(u, "tcp://"):
+		port, err := dialTCP(u)
+		if err != nil {
+			return nil, err
+		}
+		return port, nil
+	case strings.HasPrefix(u, "rfc2217://"):
+		port, err := dialRFC2217(u, c)
+		if err != nil {
+			return nil, err
+		}
+		return port, nil
+	}
+
+	port, err := openSerial(u, c)
+	if err != nil {
+		return nil, err
+	}
+	return port, nil
+}
+
+// openSerial 按串口参数打开本地串口
+func openSerial(u string, c PortConfig) (*serial.Port, error) {
+	port, err := serial.OpenPort(&serial.Config{
+		Name:        u,
+		Baud:        c.Baud,
+		Size:        byte(c.DataBits),
+		Parity:      serial.Parity(c.Parity[0]),
+		StopBits:    serial.StopBits(c.StopBits),
+		ReadTimeout: c.ReadTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// tarm/serial 不支持硬件流控，单独设置
+	if c.RTSCTS {
+		if err := setFlowControl(u, true); err != nil {
+			port.Close()
+			return nil, fmt.Errorf("failed to enable rts/cts: %w", err)
+		}
+	}
+
+	return port, nil
+}
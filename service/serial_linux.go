@@ -0,0 +1,28 @@
+//go:build linux
+
+package service
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// setFlowControl 设置串口 RTS/CTS 硬件流控
+// 终端属性作用于设备本身，因此可以通过独立的文件描述符修改
+func setFlowControl(name string, enable bool) error {
+	fd, err := unix.Open(name, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+	if enable {
+		t.Cflag |= unix.CRTSCTS
+	} else {
+		t.Cflag &^= unix.CRTSCTS
+	}
+	return unix.IoctlSetTermios(fd, unix.TCSETS, t)
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package service
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// setFlowControl 设置串口 RTS/CTS 硬件流控
+func setFlowControl(name string, enable bool) error {
+	if enable {
+		return fmt.Errorf("rts/cts flow control is not supported on %s", runtime.GOOS)
+	}
+	return nil
+}
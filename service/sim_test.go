@@ -0,0 +1,196 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rehiy/web-modem/database"
+	"github.com/rehiy/web-modem/models"
+	"github.com/rehiy/web-modem/simulator"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "web-modem-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Setenv("DB_PATH", filepath.Join(dir, "modem.db"))
+	if err := database.InitDB(); err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	database.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// connectSim 通过 ModemService 连接指定名称的模拟器，测试结束时断开
+func connectSim(t *testing.T, name string) *ModemConn {
+	t.Helper()
+	ms := GetModemService()
+	conn, err := ms.Connect(simulator.Scheme + name)
+	if err != nil {
+		t.Fatalf("connect %s: %v", name, err)
+	}
+	t.Cleanup(func() { ms.Forget(conn.Name) })
+	return conn
+}
+
+// waitFor 等待条件满足
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// receivedSms 返回设备收到的短信
+func receivedSms(t *testing.T, name string) []models.Sms {
+	t.Helper()
+	list, _, err := database.GetSmsList(&models.SmsFilter{ModemName: name, Direction: "in", Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+// storedCount 返回模拟器上存储的短信数量
+func storedCount(name string) int {
+	return simulator.Get(name).Status().Stored
+}
+
+func TestSimSendWithStatusReport(t *testing.T) {
+	conn := connectSim(t, "test-send")
+
+	text := strings.Repeat("status report ", 25) // 3 个分段
+	job, err := GetModemService().EnqueueSms(conn.Name, "+8613800000001", text, SendOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var record models.Sms
+	waitFor(t, "delivery report", func() bool {
+		list, _, err := database.GetSmsList(&models.SmsFilter{ModemName: conn.Name, Direction: "out", Limit: 1})
+		if err != nil || len(list) == 0 || list[0].ID != job.SmsID {
+			return false
+		}
+		record = list[0]
+		return record.Status == models.SmsStatusDelivered
+	})
+	if refs := splitList(record.MessageRefs); len(refs) != 3 || record.Segments != 3 {
+		t.Errorf("message refs = %q, segments = %d, want 3 segments", record.MessageRefs, record.Segments)
+	}
+	if record.DeliveredAt == nil {
+		t.Error("delivered_at is not set")
+	}
+
+	sent := simulator.Get("test-send").Status().Sent
+	if len(sent) != 3 || !sent[0].SRR {
+		t.Errorf("simulator received %d segments, want 3 with status report requested", len(sent))
+	}
+}
+
+func TestSimMultipartReceive(t *testing.T) {
+	conn := connectSim(t, "test-receive")
+
+	text := strings.Repeat("multipart ", 40) // 3 个分段
+	if err := simulator.Get("test-receive").InjectSmsParts("+8613800000002", text, 100*time.Millisecond, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "received sms", func() bool { return len(receivedSms(t, conn.Name)) > 0 })
+	list := receivedSms(t, conn.Name)
+	if len(list) != 1 || list[0].Content != text || list[0].Partial {
+		t.Fatalf("received %+v, want one complete sms", list)
+	}
+	if list[0].SendNumber != "+8613800000002" {
+		t.Errorf("send number = %s", list[0].SendNumber)
+	}
+
+	// 保存后按默认策略删除设备上的分段
+	waitFor(t, "stored sms deleted", func() bool { return storedCount("test-receive") == 0 })
+}
+
+func TestSimIngestDeletePolicy(t *testing.T) {
+	if err := database.SetIngestStoredEnabled(true); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.SetIngestStoredEnabled(false) })
+
+	cases := []struct {
+		name   string
+		policy string
+		keep   int
+		stored int
+	}{
+		{"test-ingest-all", models.SmsDeleteAll, 0, 0},
+		{"test-ingest-recent", models.SmsDeleteRecent, 1, 1},
+		{"test-ingest-keep", models.SmsDeleteKeep, 0, 3},
+	}
+
+	for _, c := range cases {
+		t.Run(c.policy, func(t *testing.T) {
+			ms := GetModemService()
+			conn := connectSim(t, c.name)
+			if _, err := ms.SetSmsPolicy(conn.Name, c.policy, c.keep); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ms.Disconnect(conn.Name); err != nil {
+				t.Fatal(err)
+			}
+
+			// 断开期间收到的短信保存在设备上
+			sim := simulator.Get(c.name)
+			for _, text := range []string{"first", "second", "third"} {
+				if err := sim.InjectSms("+8613800000003", text); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if n := storedCount(c.name); n != 3 {
+				t.Fatalf("stored = %d, want 3", n)
+			}
+
+			connectSim(t, c.name)
+			waitFor(t, "ingested sms", func() bool { return len(receivedSms(t, conn.Name)) == 3 })
+			waitFor(t, "delete policy", func() bool { return storedCount(c.name) == c.stored })
+
+			// 再次处理时不重复保存
+			ms.mu.Lock()
+			pooled := ms.pool[conn.Name]
+			ms.mu.Unlock()
+			ms.ingestStored(pooled)
+			if n := len(receivedSms(t, conn.Name)); n != 3 {
+				t.Errorf("received = %d after second ingest, want 3", n)
+			}
+		})
+	}
+}
+
+func TestSetAliasConflict(t *testing.T) {
+	ms := GetModemService()
+	a := connectSim(t, "test-alias-a")
+	b := connectSim(t, "test-alias-b")
+
+	if _, err := ms.SetAlias(a.Name, "office"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ms.SetAlias(b.Name, "office"); !errors.Is(err, database.ErrAliasExists) {
+		t.Fatalf("set duplicate alias: %v, want ErrAliasExists", err)
+	}
+	if _, err := ms.SetAlias(a.Name, "office"); err != nil {
+		t.Errorf("set same alias again: %v", err)
+	}
+	if conn, err := ms.GetConn("office"); err != nil || conn.Name != a.Name {
+		t.Errorf("alias resolves to %v, want %s", conn, a.Name)
+	}
+}
@@ -1,9 +1,12 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"log"
 
+	"github.com/rehiy/modem/at"
+
 	"github.com/rehiy/web-modem/database"
 	"github.com/rehiy/web-modem/models"
 )
@@ -29,7 +32,11 @@ func (s *SmsdbService) SyncSmsToDB(modemName string) (map[string]any, error) {
 	}
 
 	// 列出所有短信（stat=4 表示所有短信）
-	smsList, err := conn.ListSmsPdu(4)
+	var smsList []at.Sms
+	err = conn.Exec(context.Background(), PriorityQuery, "AT+CMGL", func() error {
+		smsList, err = conn.ListSmsPdu(4)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("读取短信失败: %v", err)
 	}
@@ -40,7 +47,7 @@ func (s *SmsdbService) SyncSmsToDB(modemName string) (map[string]any, error) {
 	// 同步每条短信
 	for _, atSms := range smsList {
 		// 转换为数据库模型
-		modelSms := atSmsToModelSms(atSms, conn.Number, modemName)
+		modelSms := atSmsToModelSms(atSms, conn)
 
 		// 检查是否已存在
 		if res, err := database.GetSmsListByIDs(atSms.Indices); err == nil && len(res) > 0 {
@@ -59,24 +66,19 @@ func (s *SmsdbService) SyncSmsToDB(modemName string) (map[string]any, error) {
 	}
 
 	return map[string]any{
-		"modemName":  modemName,
+		"modemName":  conn.Name,
 		"totalCount": totalCount,
 		"newCount":   newCount,
 	}, nil
 }
 
-// HandleIncomingSms 处理接收到的短信：保存到数据库
-func (w *SmsdbService) HandleIncomingSms(dbSms *models.Sms) {
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("[Webhook] Panic recovered: %v", r)
-			}
-		}()
-		if database.IsSmsdbEnabled() {
-			if err := database.CreateSms(dbSms); err != nil {
-				log.Printf("[Sms] Failed to save incoming Sms: %v", err)
-			}
-		}
-	}()
+// HandleIncomingSms 处理接收到的短信：保存到数据库，返回是否已保存，未启用短信存储时不保存
+func (w *SmsdbService) HandleIncomingSms(dbSms *models.Sms) (bool, error) {
+	if !database.IsSmsdbEnabled() {
+		return false, nil
+	}
+	if err := database.CreateSms(dbSms); err != nil {
+		return false, fmt.Errorf("failed to save incoming Sms: %w", err)
+	}
+	return true, nil
 }
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rehiy/web-modem/database"
+)
+
+// storageRefresh 查询设备短信存储使用情况的间隔
+var storageRefresh = 5 * time.Minute
+
+// SmsStorage 短信存储区域的使用情况
+type SmsStorage struct {
+	Mem   string `json:"mem"`   // 存储区域，如 SM、ME
+	Used  int    `json:"used"`  // 已用条数
+	Total int    `json:"total"` // 总容量
+}
+
+// overThreshold 检查使用率是否达到阈值（百分比）
+func (s SmsStorage) overThreshold(percent int) bool {
+	return percent > 0 && s.Total > 0 && s.Used*100 >= s.Total*percent
+}
+
+// GetSmsStorage 查询短信存储区域的使用情况，按读取、写入、接收的顺序，相同区域只返回一次
+func (c *ModemConn) GetSmsStorage() ([]SmsStorage, error) {
+	resp, err := c.SendCommand("AT+CPMS?")
+	if err = commandError(resp, err); err != nil {
+		return nil, err
+	}
+
+	// 响应格式: "+CPMS: <mem1>,<used1>,<total1>[,<mem2>,<used2>,<total2>[,<mem3>,<used3>,<total3>]]"
+	for _, line := range resp {
+		v, ok := strings.CutPrefix(line, "+CPMS:")
+		if !ok {
+			continue
+		}
+		params := strings.Split(v, ",")
+		if len(params) < 3 || len(params)%3 != 0 {
+			return nil, fmt.Errorf("invalid response: %s", line)
+		}
+		list := []SmsStorage{}
+		seen := map[string]bool{}
+		for i := 0; i < len(params); i += 3 {
+			mem := strings.Trim(strings.TrimSpace(params[i]), `"`)
+			used, err1 := strconv.Atoi(strings.TrimSpace(params[i+1]))
+			total, err2 := strconv.Atoi(strings.TrimSpace(params[i+2]))
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid response: %s", line)
+			}
+			if !seen[mem] {
+				seen[mem] = true
+				list = append(list, SmsStorage{Mem: mem, Used: used, Total: total})
+			}
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("no +CPMS in response")
+}
+
+// GetStorage 返回定期刷新的设备短信存储使用情况
+func (m *ModemService) GetStorage(u string) ([]SmsStorage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn := m.lookupConn(u)
+	if conn == nil {
+		return nil, fmt.Errorf("[%s] not found", u)
+	}
+	return slices.Clone(conn.Storage), nil
+}
+
+// refreshStorage 刷新设备短信存储的使用情况，使用率达到阈值时发布告警事件，
+// 启用自动清理时删除最早的已归档短信
+func (m *ModemService) refreshStorage(conn *ModemConn) {
+	percent, purge := 0, false
+	if database.GetDB() != nil {
+		percent, purge = database.GetStorageWarnPercent(), database.IsStoragePurgeEnabled()
+	}
+
+	storage := m.updateStorage(conn, percent)
+	if len(storage) == 0 || !purge || !storage[0].overThreshold(percent) {
+		return
+	}
+
+	// 读取和删除短信使用第一个存储区域，删除到使用率低于阈值为止
+	allowed := (storage[0].Total*percent+99)/100 - 1
+	if m.purgeStored(conn, storage[0].Used-allowed) {
+		m.updateStorage(conn, percent)
+	}
+}
+
+// updateStorage 查询设备短信存储的使用情况并检查告警阈值，查询失败时返回 nil
+func (m *ModemService) updateStorage(conn *ModemConn, percent int) []SmsStorage {
+	var storage []SmsStorage
+	err := conn.Exec(context.Background(), PriorityQuery, "AT+CPMS", func() error {
+		var err error
+		storage, err = conn.GetSmsStorage()
+		return err
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn.storageAt = time.Now()
+	if err != nil {
+		log.Printf("[%s] failed to refresh sms storage: %v", conn.Name, err)
+		return nil
+	}
+	conn.Storage = storage
+
+	// 每个区域达到阈值时告警一次，降到阈值以下后重新告警
+	if conn.warned == nil {
+		conn.warned = map[string]bool{}
+	}
+	for _, s := range storage {
+		if !s.overThreshold(percent) {
+			delete(conn.warned, s.Mem)
+			continue
+		}
+		if !conn.warned[s.Mem] {
+			conn.warned[s.Mem] = true
+			log.Printf("[%s] sms storage %s is %d/%d full", conn.Name, s.Mem, s.Used, s.Total)
+			emitEvent(conn.Name, "storage_warning", fmt.Sprintf("%s %d/%d", s.Mem, s.Used, s.Total))
+		}
+	}
+	return storage
+}
+
+// purgeStored 从最早的短信开始删除设备上已保存到数据库的短信，至少删除 count 个分段，
+// 长短信的分段一起删除，返回是否删除了短信
+func (m *ModemService) purgeStored(conn *ModemConn, count int) bool {
+	if count <= 0 {
+		return false
+	}
+
+	list, err := conn.readStoredList(PriorityQuery)
+	if err != nil {
+		log.Printf("[%s] failed to list stored Sms: %v", conn.Name, err)
+		return false
+	}
+
+	msgs := groupStored(list, func(fingerprint string) bool {
+		archived, err := database.IsArchivedPdu(conn.Name, fingerprint)
+		if err != nil {
+			log.Printf("[%s] %v", conn.Name, err)
+		}
+		return archived
+	})
+
+	indices := []int{}
+	for i := len(msgs) - 1; i >= 0 && len(indices) < count; i-- {
+		indices = append(indices, msgs[i].indices...)
+	}
+	if len(indices) == 0 {
+		log.Printf("[%s] sms storage is full but no archived Sms to purge", conn.Name)
+		return false
+	}
+
+	log.Printf("[%s] purging %d archived Sms segments", conn.Name, len(indices))
+	m.deleteStored(conn, indices)
+	return true
+}
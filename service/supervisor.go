@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	superviseInterval   = 10 * time.Second // 连接检测间隔
+	reconnectMinBackoff = 2 * time.Second  // 重连最小等待时间
+	reconnectMaxBackoff = 2 * time.Minute  // 重连最大等待时间
+)
+
+// superviseConn 定期检测连接状态，失败时按指数退避重新连接
+func (m *ModemService) superviseConn(conn *ModemConn) {
+	wait := superviseInterval
+	backoff := reconnectMinBackoff
+
+	for {
+		select {
+		case <-conn.stop:
+			return
+		case <-time.After(wait):
+		}
+
+		// 检测连接是否正常
+		m.mu.Lock()
+		dev, connected := conn.Device, conn.Connected
+		m.mu.Unlock()
+
+		if connected {
+			err := conn.Exec(context.Background(), PriorityURC, "AT", dev.Test)
+			if err == nil {
+				m.mu.Lock()
+				stale := time.Since(conn.netAt) >= networkRefresh
+				ingestAt, stuck := conn.ingestAt, conn.Stuck
+				storageDue := time.Since(conn.storageAt) >= storageRefresh
+				m.mu.Unlock()
+				if stale {
+					m.refreshNetwork(conn, dev)
+				}
+				if ingestDue(ingestAt) {
+					m.ingestStored(conn)
+				} else if stale && stuck > 0 {
+					m.tidyStored(conn, nil) // 重试删除失败的短信
+				}
+				if storageDue {
+					m.refreshStorage(conn)
+				}
+				m.pruneReceived(conn, nil)
+				wait, backoff = superviseInterval, reconnectMinBackoff
+				continue
+			}
+
+			m.mu.Lock()
+			if conn.Device == dev && conn.Connected {
+				conn.Connected = false
+				conn.LastError = err.Error()
+				dev.Close()
+				log.Printf("[%s] connection lost: %v", conn.Name, err)
+				emitEvent(conn.Name, "disconnected", conn.Port)
+			}
+			m.mu.Unlock()
+		}
+
+		// 重新打开端口
+		m.mu.Lock()
+		if m.pool[conn.Name] != conn {
+			// 已从连接池中移除
+			m.mu.Unlock()
+			return
+		}
+		if conn.Connected || m.ignored[conn.Port] {
+			// 已被其他流程重新连接，或端口已手动断开
+			m.mu.Unlock()
+			wait, backoff = superviseInterval, reconnectMinBackoff
+			continue
+		}
+		port := conn.Port
+		m.mu.Unlock()
+
+		err := m.makeConnect(port, nil)
+
+		m.mu.Lock()
+		if err == nil && !conn.Connected {
+			err = fmt.Errorf("port %s is now used by another device", conn.Port)
+		}
+		if err == nil {
+			conn.Reconnects++
+			emitEvent(conn.Name, "reconnected", conn.Port)
+		}
+		m.mu.Unlock()
+
+		if err != nil {
+			log.Printf("[%s] reconnect failed, retry in %v: %v", conn.Name, backoff, err)
+			wait = backoff
+			backoff = min(backoff*2, reconnectMaxBackoff)
+			continue
+		}
+		wait, backoff = superviseInterval, reconnectMinBackoff
+	}
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sysTTYPath Linux 下 tty 设备的 sysfs 目录
+const sysTTYPath = "/sys/class/tty"
+
+// usbATInterfaces 常见型号的 AT 接口编号，键为 VID:PID
+var usbATInterfaces = map[string]int{
+	"2c7c:0121": 2, // Quectel EC21
+	"2c7c:0125": 2, // Quectel EC25/EC20
+	"2c7c:0296": 2, // Quectel BG96
+	"2c7c:0306": 2, // Quectel EP06/EG06
+	"2c7c:0800": 2, // Quectel RM500Q
+	"1e0e:9001": 2, // SIMCom SIM7600/SIM7000
+}
+
+// USBInfo 端口所属 USB 设备的信息
+type USBInfo struct {
+	VID          string `json:"vid"`
+	PID          string `json:"pid"`
+	Serial       string `json:"serial"`
+	Manufacturer string `json:"manufacturer"`
+	Product      string `json:"product"`
+	Interface    int    `json:"interface"` // USB 接口编号
+	Driver       string `json:"driver"`
+	BusPath      string `json:"bus_path"` // USB 总线路径，如 1-1.2，用于区分物理设备
+}
+
+// readUSBInfo 从 sysfs 读取端口所属 USB 设备的信息，非 USB 端口返回 nil
+func readUSBInfo(u string) *USBInfo {
+	if runtime.GOOS != "linux" || !strings.HasPrefix(u, "/dev/") {
+		return nil
+	}
+
+	dev, err := filepath.EvalSymlinks(filepath.Join(sysTTYPath, filepath.Base(u), "device"))
+	if err != nil {
+		return nil
+	}
+
+	// 向上查找 USB 接口目录，其父目录为 USB 设备目录
+	for dir := dev; dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		ifnum := readSysfs(dir, "bInterfaceNumber")
+		if ifnum == "" {
+			continue
+		}
+		parent := filepath.Dir(dir)
+		info := &USBInfo{
+			VID:          readSysfs(parent, "idVendor"),
+			PID:          readSysfs(parent, "idProduct"),
+			Serial:       readSysfs(parent, "serial"),
+			Manufacturer: readSysfs(parent, "manufacturer"),
+			Product:      readSysfs(parent, "product"),
+			BusPath:      filepath.Base(parent),
+		}
+		if v, err := strconv.ParseInt(ifnum, 16, 0); err == nil {
+			info.Interface = int(v)
+		}
+		if drv, err := os.Readlink(filepath.Join(dir, "driver")); err == nil {
+			info.Driver = filepath.Base(drv)
+		}
+		return info
+	}
+
+	return nil
+}
+
+// readSysfs 读取 sysfs 属性文件
+func readSysfs(dir, name string) string {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// readUSBInfos 读取端口所属 USB 设备的信息，跳过非 USB 端口；读取 sysfs 较慢，不要在持有锁时调用
+func readUSBInfos(ports []string) map[string]*USBInfo {
+	infos := map[string]*USBInfo{}
+	for _, u := range ports {
+		if info := readUSBInfo(u); info != nil {
+			infos[u] = info
+		}
+	}
+	return infos
+}
+
+// groupPorts 按 USB 设备对端口分组，组内按可能的 AT 接口优先排序，infos 由 readUSBInfos 读取，调用方需持有锁
+func (m *ModemService) groupPorts(ports []string, infos map[string]*USBInfo) [][]string {
+	groups := [][]string{}
+	buses := []string{}
+	byBus := map[string][]string{}
+
+	for _, u := range ports {
+		info := infos[u]
+		if info == nil {
+			groups = append(groups, []string{u})
+			continue
+		}
+		if _, ok := byBus[info.BusPath]; !ok {
+			buses = append(buses, info.BusPath)
+		}
+		byBus[info.BusPath] = append(byBus[info.BusPath], u)
+	}
+
+	for _, bus := range buses {
+		group := byBus[bus]
+
+		// 同一设备已连接或已手动断开
+		if conn := m.connByBus(bus); conn != nil {
+			if m.ignored[conn.Port] {
+				continue
+			}
+			if conn.Connected {
+				if slices.Contains(group, conn.Port) {
+					groups = append(groups, []string{conn.Port})
+				}
+				continue
+			}
+		}
+
+		sort.SliceStable(group, func(i, j int) bool {
+			return m.portRank(group[i], infos[group[i]]) < m.portRank(group[j], infos[group[j]])
+		})
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+// portRank 端口尝试顺序：上次使用的端口 > 已知 AT 接口 > 按接口编号，调用方需持有锁
+func (m *ModemService) portRank(u string, info *USBInfo) int {
+	if m.connByPort(u) != nil {
+		return 0
+	}
+	if n, ok := usbATInterfaces[info.VID+":"+info.PID]; ok && n == info.Interface {
+		return 1
+	}
+	return 2 + info.Interface
+}
+
+// connByBus 按 USB 总线路径查找连接，优先返回已连接的设备，调用方需持有锁
+func (m *ModemService) connByBus(bus string) *ModemConn {
+	var found *ModemConn
+	for _, conn := range m.pool {
+		if conn.USB == nil || conn.USB.BusPath != bus {
+			continue
+		}
+		if conn.Connected {
+			return conn
+		}
+		found = conn
+	}
+	return found
+}
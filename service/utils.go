@@ -9,15 +9,16 @@ import (
 )
 
 // atSmsToModelSms 将AT短信转换为数据库模型
-func atSmsToModelSms(atSms at.Sms, receiveNumber string, modemName string) *models.Sms {
+func atSmsToModelSms(atSms at.Sms, conn *ModemConn) *models.Sms {
 	return &models.Sms{
 		Content:       atSms.Text,
 		SmsIDs:        database.IntArrayToString(atSms.Indices),
 		ReceiveTime:   parseSmsTime(atSms.Time),
-		ReceiveNumber: receiveNumber,
+		ReceiveNumber: conn.Number,
 		SendNumber:    atSms.Number,
 		Direction:     "in",
-		ModemName:     modemName,
+		ModemName:     conn.Name,
+		ICCID:         conn.ICCID,
 	}
 }
 
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"log"
+	"path"
+	"runtime"
+	"time"
+)
+
+// WatchModems 监听串口设备的插拔，自动连接新设备并标记已移除的设备
+func (m *ModemService) WatchModems(interval time.Duration) {
+	if runtime.GOOS == "windows" {
+		log.Printf("[Watcher] hotplug detection is not supported on %s", runtime.GOOS)
+		return
+	}
+
+	log.Printf("[Watcher] watching modem ports every %v", interval)
+	for {
+		m.checkPorts()
+		time.Sleep(interval)
+	}
+}
+
+// checkPorts 对比端口变化，处理新增和移除的设备
+func (m *ModemService) checkPorts() {
+	m.mu.Lock()
+
+	ports := m.findPorts()
+
+	present := map[string]bool{}
+	added := []string{}
+	for _, u := range ports {
+		present[u] = true
+		if m.watched[u] {
+			// 上次未能连接的端口，如设备尚未就绪
+			if m.retry[u] && !m.ignored[u] {
+				added = append(added, u)
+			}
+			continue
+		}
+		// 新出现的端口
+		emitEvent(path.Base(u), "attached", u)
+		if !m.ignored[u] {
+			added = append(added, u)
+		}
+	}
+
+	// 已移除的端口
+	for u := range m.watched {
+		if present[u] {
+			continue
+		}
+		n := path.Base(u)
+		delete(m.retry, u)
+		emitEvent(n, "detached", u)
+		if conn := m.connByPort(u); conn != nil && conn.Connected {
+			conn.Connected = false
+			conn.Close()
+			log.Printf("[%s] port removed, %s marked as disconnected", n, conn.Name)
+			emitEvent(conn.Name, "disconnected", u)
+		}
+	}
+
+	m.watched = present
+	m.mu.Unlock()
+
+	// 连接新设备，不持有锁
+	errs := m.connectPorts(added, nil)
+	for u, err := range errs {
+		if err == nil {
+			emitEvent(m.nameOfPort(u), "connected", u)
+		}
+	}
+
+	// 连接失败的端口下次重试，未尝试的端口属于已连接的设备
+	m.mu.Lock()
+	for _, u := range added {
+		if errs[u] != nil && m.watched[u] {
+			m.retry[u] = true
+		} else {
+			delete(m.retry, u)
+		}
+	}
+	m.mu.Unlock()
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +19,12 @@ import (
 // WebhookService webhook服务
 type WebhookService struct{}
 
+// Webhook 事件
+const (
+	WebhookSmsReceived = "sms_received" // 收到短信
+	WebhookSmsStatus   = "sms_status"   // 发送短信的状态报告
+)
+
 var (
 	webhookCache     []models.Webhook
 	webhookCacheTime time.Time
@@ -53,24 +61,41 @@ func (w *WebhookService) getCachedWebhooks() ([]models.Webhook, error) {
 	return webhooks, nil
 }
 
-// TriggerWebhooks 触发所有启用的webhook
-func (w *WebhookService) TriggerWebhooks(sms *models.Sms) error {
+// TriggerWebhooks 触发所有订阅了事件的启用的webhook
+func (w *WebhookService) TriggerWebhooks(event string, sms *models.Sms) error {
+	_, err := w.triggerWebhooks(event, sms, nil)
+	return err
+}
+
+// triggerWebhooks 触发订阅了事件且不在 delivered 中的启用的webhook，返回已成功触发的webhook ID（包括 delivered）
+func (w *WebhookService) triggerWebhooks(event string, sms *models.Sms, delivered []int) ([]int, error) {
 	if !database.IsWebhookEnabled() {
-		return nil
+		return delivered, nil
 	}
 
 	webhooks, err := w.getCachedWebhooks()
 	if err != nil {
-		return fmt.Errorf("failed to get enabled webhooks: %w", err)
+		return delivered, fmt.Errorf("failed to get enabled webhooks: %w", err)
+	}
+
+	// 过滤未订阅事件或已成功触发的webhook
+	subscribed := []models.Webhook{}
+	for _, wh := range webhooks {
+		if w.subscribes(&wh, event) && !slices.Contains(delivered, wh.ID) {
+			subscribed = append(subscribed, wh)
+		}
 	}
+	webhooks = subscribed
 
 	if len(webhooks) == 0 {
-		log.Printf("[Webhook] No enabled webhooks found")
-		return nil
+		log.Printf("[Webhook] No enabled webhooks found for %s", event)
+		return delivered, nil
 	}
 
 	// 使用并发控制触发webhook
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := slices.Clone(delivered)
 	semaphore := make(chan struct{}, 5) // 限制并发数为5
 
 	for _, webhook := range webhooks {
@@ -81,18 +106,39 @@ func (w *WebhookService) TriggerWebhooks(sms *models.Sms) error {
 			defer wg.Done()
 			defer func() { <-semaphore }() // 释放信号量
 
-			w.triggerWebhook(&wh, sms)
+			if w.triggerWebhook(&wh, event, sms) == nil {
+				mu.Lock()
+				succeeded = append(succeeded, wh.ID)
+				mu.Unlock()
+			}
 		}(webhook)
 	}
 
 	wg.Wait()
-	log.Printf("[Webhook] Successfully triggered %d webhooks for Sms", len(webhooks))
+	if n := len(delivered) + len(webhooks) - len(succeeded); n > 0 {
+		return succeeded, fmt.Errorf("%d of %d webhooks failed for %s", n, len(webhooks), event)
+	}
+	log.Printf("[Webhook] Successfully triggered %d webhooks for %s", len(webhooks), event)
+
+	return succeeded, nil
+}
 
-	return nil
+// subscribes 检查webhook是否订阅了事件，未设置时只订阅收到短信
+func (w *WebhookService) subscribes(webhook *models.Webhook, event string) bool {
+	events := splitList(webhook.Events)
+	if len(events) == 0 {
+		return event == WebhookSmsReceived
+	}
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
 }
 
 // triggerWebhook 触发单个webhook，支持重试机制
-func (w *WebhookService) triggerWebhook(webhook *models.Webhook, sms *models.Sms) error {
+func (w *WebhookService) triggerWebhook(webhook *models.Webhook, event string, sms *models.Sms) error {
 	maxRetries := 3
 	retryDelay := 2 * time.Second
 
@@ -104,7 +150,7 @@ func (w *WebhookService) triggerWebhook(webhook *models.Webhook, sms *models.Sms
 		}
 
 		// 准备payload
-		payload, err := w.preparePayload(webhook, sms)
+		payload, err := w.preparePayload(webhook, event, sms)
 		if err != nil {
 			log.Printf("[Webhook] Failed to prepare payload for %s: %v", webhook.Name, err)
 			return err // 模板错误不重试
@@ -157,10 +203,10 @@ func (w *WebhookService) triggerWebhook(webhook *models.Webhook, sms *models.Sms
 }
 
 // preparePayload 准备webhook payload
-func (w *WebhookService) preparePayload(webhook *models.Webhook, sms *models.Sms) ([]byte, error) {
+func (w *WebhookService) preparePayload(webhook *models.Webhook, event string, sms *models.Sms) ([]byte, error) {
 	// 如果template为空或不是有效的JSON，使用默认模板
 	if webhook.Template == "" || webhook.Template == "{}" {
-		return w.getDefaultPayload(sms)
+		return w.getDefaultPayload(event, sms)
 	}
 
 	// 尝试解析模板
@@ -168,28 +214,41 @@ func (w *WebhookService) preparePayload(webhook *models.Webhook, sms *models.Sms
 	if err := json.Unmarshal([]byte(webhook.Template), &template); err != nil {
 		// 如果模板解析失败，使用默认模板
 		log.Printf("[Webhook] Invalid template for %s, using default: %v", webhook.Name, err)
-		return w.getDefaultPayload(sms)
+		return w.getDefaultPayload(event, sms)
 	}
 
 	// 替换模板中的变量
-	payload := w.replaceTemplateVariables(template, sms)
+	payload := w.replaceTemplateVariables(template, event, sms)
 
 	return json.Marshal(payload)
 }
 
 // getDefaultPayload 获取默认payload
-func (w *WebhookService) getDefaultPayload(sms *models.Sms) ([]byte, error) {
+func (w *WebhookService) getDefaultPayload(event string, sms *models.Sms) ([]byte, error) {
+	data := map[string]any{
+		"id":             sms.ID,
+		"content":        sms.Content,
+		"sms_ids":        sms.SmsIDs,
+		"receive_time":   sms.ReceiveTime.Format(time.RFC3339),
+		"receive_number": sms.ReceiveNumber,
+		"send_number":    sms.SendNumber,
+		"direction":      sms.Direction,
+		"modem_name":     sms.ModemName,
+		"iccid":          sms.ICCID,
+	}
+	if event == WebhookSmsReceived {
+		data["partial"] = sms.Partial
+	}
+	if event == WebhookSmsStatus {
+		data["status"] = sms.Status
+		data["message_refs"] = sms.MessageRefs
+		data["error"] = sms.Error
+		data["delivered_at"] = formatTime(sms.DeliveredAt)
+	}
+
 	payload := map[string]any{
-		"event": "sms_received",
-		"data": map[string]any{
-			"id":             sms.ID,
-			"content":        sms.Content,
-			"sms_ids":        sms.SmsIDs,
-			"receive_time":   sms.ReceiveTime.Format(time.RFC3339),
-			"receive_number": sms.ReceiveNumber,
-			"send_number":    sms.SendNumber,
-			"direction":      sms.Direction,
-		},
+		"event":     event,
+		"data":      data,
 		"timestamp": time.Now().Unix(),
 	}
 
@@ -197,15 +256,15 @@ func (w *WebhookService) getDefaultPayload(sms *models.Sms) ([]byte, error) {
 }
 
 // replaceTemplateVariables 替换模板中的变量
-func (w *WebhookService) replaceTemplateVariables(template map[string]any, sms *models.Sms) map[string]any {
+func (w *WebhookService) replaceTemplateVariables(template map[string]any, event string, sms *models.Sms) map[string]any {
 	result := make(map[string]any)
 
 	for key, value := range template {
 		switch v := value.(type) {
 		case string:
-			result[key] = w.replaceStringVariables(v, sms)
+			result[key] = w.replaceStringVariables(v, event, sms)
 		case map[string]any:
-			result[key] = w.replaceTemplateVariables(v, sms)
+			result[key] = w.replaceTemplateVariables(v, event, sms)
 		default:
 			result[key] = value
 		}
@@ -215,14 +274,21 @@ func (w *WebhookService) replaceTemplateVariables(template map[string]any, sms *
 }
 
 // replaceStringVariables 替换字符串中的变量
-func (w *WebhookService) replaceStringVariables(s string, sms *models.Sms) string {
+func (w *WebhookService) replaceStringVariables(s string, event string, sms *models.Sms) string {
 	replacements := map[string]string{
+		"{{event}}":          event,
 		"{{content}}":        sms.Content,
 		"{{sms_ids}}":        sms.SmsIDs,
 		"{{receive_time}}":   sms.ReceiveTime.Format(time.RFC3339),
 		"{{receive_number}}": sms.ReceiveNumber,
 		"{{send_number}}":    sms.SendNumber,
 		"{{direction}}":      sms.Direction,
+		"{{modem_name}}":     sms.ModemName,
+		"{{iccid}}":          sms.ICCID,
+		"{{status}}":         sms.Status,
+		"{{error}}":          sms.Error,
+		"{{delivered_at}}":   formatTime(sms.DeliveredAt),
+		"{{partial}}":        strconv.FormatBool(sms.Partial),
 	}
 
 	for old, new := range replacements {
@@ -244,11 +310,25 @@ func (w *WebhookService) TestWebhook(webhook *models.Webhook) error {
 		Direction:     "in",
 	}
 
-	return w.triggerWebhook(webhook, testSms)
+	return w.triggerWebhook(webhook, WebhookSmsReceived, testSms)
 }
 
-// HandleIncomingSms 处理接收到的短信：触发 webhook
-func (w *WebhookService) HandleIncomingSms(dbSms *models.Sms) {
+// HandleIncomingSms 处理接收到的短信：触发 delivered 以外的 webhook，返回已成功触发的 webhook ID，任一 webhook 失败时返回错误
+func (w *WebhookService) HandleIncomingSms(dbSms *models.Sms, delivered []int) (hooked []int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[Webhook] Panic recovered: %v", r)
+			hooked, err = delivered, fmt.Errorf("webhook panic: %v", r)
+		}
+	}()
+	if !database.IsWebhookEnabled() {
+		return delivered, nil
+	}
+	return w.triggerWebhooks(WebhookSmsReceived, dbSms, delivered)
+}
+
+// HandleSmsStatus 处理发送短信的状态报告：触发 webhook
+func (w *WebhookService) HandleSmsStatus(dbSms *models.Sms) {
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
@@ -256,9 +336,17 @@ func (w *WebhookService) HandleIncomingSms(dbSms *models.Sms) {
 			}
 		}()
 		if database.IsWebhookEnabled() {
-			if err := w.TriggerWebhooks(dbSms); err != nil {
+			if err := w.TriggerWebhooks(WebhookSmsStatus, dbSms); err != nil {
 				log.Printf("[Webhook] Failed to trigger webhooks: %v", err)
 			}
 		}
 	}()
 }
+
+// formatTime 格式化可选时间，为空时返回空字符串
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
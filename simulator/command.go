@@ -0,0 +1,494 @@
+package simulator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 输入控制字符
+const (
+	ctrlZ = 0x1A // 结束 PDU 输入并发送
+	esc   = 0x1B // 取消 PDU 输入
+)
+
+// CMS 错误码
+const (
+	cmsOperationNotAllowed = 302
+	cmsInvalidPduParam     = 304
+	cmsInvalidMemoryIndex  = 321
+	cmsNoNetworkService    = 331
+	cmsNoCnmaExpected      = 340
+)
+
+// result 命令执行结果
+type result struct {
+	lines []string
+	final string
+}
+
+// ok 返回成功结果
+func ok(lines ...string) result {
+	return result{lines: lines, final: "OK"}
+}
+
+// input 处理端口写入的数据，调用方不能持有锁
+func (m *Modem) input(p *Port, b []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range b {
+		// PDU 输入模式
+		if p.pduMode {
+			switch c {
+			case ctrlZ:
+				p.pduMode = false
+				pdu := strings.TrimSpace(string(p.line))
+				p.line = nil
+				p.send(m.reply(m.submit(pdu, p.pduLen)))
+			case esc:
+				p.pduMode = false
+				p.line = nil
+				p.send("\r\nOK\r\n")
+			default:
+				p.line = append(p.line, c)
+				if m.echo {
+					p.send(string(c))
+				}
+			}
+			continue
+		}
+
+		// 命令输入模式
+		if m.echo {
+			p.send(string(c))
+		}
+		switch c {
+		case '\r':
+			line := strings.TrimSpace(string(p.line))
+			p.line = nil
+			if line != "" {
+				m.execute(p, line)
+			}
+		case '\n':
+		default:
+			p.line = append(p.line, c)
+		}
+	}
+}
+
+// execute 执行一条 AT 命令，调用方需持有锁
+func (m *Modem) execute(p *Port, line string) {
+	if len(line) < 2 || !strings.EqualFold(line[:2], "AT") {
+		p.send("\r\nERROR\r\n")
+		return
+	}
+
+	name, op, args := splitCommand(line[2:])
+
+	// 短信发送进入 PDU 输入模式
+	if name == "+CMGS" && op == "=" {
+		res, pduLen := m.cmgs(args)
+		if res.final != "" {
+			p.send(m.reply(res))
+			return
+		}
+		p.pduMode = true
+		p.pduLen = pduLen
+		p.send("\r\n> ")
+		return
+	}
+
+	p.send(m.reply(m.command(name, op, args)))
+}
+
+// reply 格式化命令响应，调用方需持有锁
+func (m *Modem) reply(res result) string {
+	var sb strings.Builder
+	for _, line := range res.lines {
+		sb.WriteString("\r\n" + line + "\r\n")
+	}
+	sb.WriteString("\r\n" + res.final + "\r\n")
+	return sb.String()
+}
+
+// fail 返回通用错误
+func (m *Modem) fail() result {
+	if m.cmee > 0 {
+		return result{final: "+CME ERROR: 4"}
+	}
+	return result{final: "ERROR"}
+}
+
+// cmsError 返回短信错误
+func cmsError(code int) result {
+	return result{final: fmt.Sprintf("+CMS ERROR: %d", code)}
+}
+
+// splitCommand 拆分命令名称、操作符和参数，例如 +CMGF=0 -> +CMGF, =, 0
+func splitCommand(body string) (string, string, string) {
+	i := strings.IndexAny(body, "=?")
+	if i < 0 {
+		return strings.ToUpper(body), "", ""
+	}
+	name := strings.ToUpper(body[:i])
+	rest := body[i:]
+	switch {
+	case rest == "?":
+		return name, "?", ""
+	case rest == "=?":
+		return name, "=?", ""
+	case strings.HasPrefix(rest, "="):
+		return name, "=", rest[1:]
+	}
+	return name, rest, ""
+}
+
+// splitArgs 拆分命令参数，去除引号
+func splitArgs(args string) []string {
+	list := []string{}
+	for _, v := range strings.Split(args, ",") {
+		list = append(list, strings.Trim(strings.TrimSpace(v), `"`))
+	}
+	return list
+}
+
+// command 执行普通命令，调用方需持有锁
+func (m *Modem) command(name, op, args string) result {
+	switch name {
+	// 基本控制
+	case "":
+		return ok()
+	case "E0", "E1":
+		m.echo = name == "E1"
+		return ok()
+	case "Z", "&F":
+		m.echo, m.cmgf, m.cmee = true, 0, 0
+		return ok()
+	case "&W", "+CFUN":
+		if op == "?" {
+			return ok("+CFUN: 1")
+		}
+		return ok()
+	case "I":
+		return ok("WebModem", "Simulator", "Revision: SIM_1.0")
+	case "+CMEE":
+		if op == "?" {
+			return ok(fmt.Sprintf("+CMEE: %d", m.cmee))
+		}
+		m.cmee, _ = strconv.Atoi(args)
+		return ok()
+
+	// 设备身份
+	case "+CGMI", "+GMI":
+		return ok("WebModem")
+	case "+CGMM", "+GMM":
+		return ok("Simulator")
+	case "+CGMR", "+GMR":
+		return ok("SIM_1.0")
+	case "+CGSN", "+GSN":
+		if op == "=?" {
+			return ok()
+		}
+		return ok(m.imei)
+	case "+CIMI":
+		return ok(m.imsi)
+	case "+CCID", "+ICCID", "+QCCID":
+		return ok("+CCID: " + m.iccid)
+	case "+CNUM":
+		return ok(fmt.Sprintf(`+CNUM: "","%s",%d`, m.number, numberType(m.number)))
+	case "+CPIN":
+		if op == "?" {
+			return ok("+CPIN: READY")
+		}
+		return ok()
+	case "+CSCS":
+		if op == "?" {
+			return ok(`+CSCS: "GSM"`)
+		}
+		return ok()
+	case "+CCLK":
+		if op == "?" {
+			return ok(time.Now().Format(`+CCLK: "06/01/02,15:04:05+32"`))
+		}
+		return ok()
+
+	// 网络状态
+	case "+CREG", "+CGREG", "+CEREG":
+		if op == "?" {
+			return ok(fmt.Sprintf("%s: %d,%d", name, m.creg, m.stat))
+		}
+		if op == "=" && name == "+CREG" {
+			m.creg, _ = strconv.Atoi(args)
+		}
+		return ok()
+	case "+COPS":
+		return m.cops(op)
+	case "+CSQ":
+		return ok(fmt.Sprintf("+CSQ: %d,99", m.rssi))
+	case "+CUSD":
+		return m.cusd(op, args)
+
+	// 短信
+	case "+CSCA":
+		if op == "?" {
+			return ok(fmt.Sprintf(`+CSCA: "%s",%d`, m.smsc, numberType(m.smsc)))
+		}
+		if op == "=" {
+			m.smsc = splitArgs(args)[0]
+		}
+		return ok()
+	case "+CMGF":
+		if op == "?" {
+			return ok(fmt.Sprintf("+CMGF: %d", m.cmgf))
+		}
+		if op == "=" {
+			v, err := strconv.Atoi(args)
+			if err != nil || v < 0 || v > 1 {
+				return m.fail()
+			}
+			m.cmgf = v
+		}
+		return ok()
+	case "+CSMS":
+		if op == "?" {
+			return ok(fmt.Sprintf("+CSMS: %d,1,1,1", m.csms))
+		}
+		if op == "=" {
+			m.csms, _ = strconv.Atoi(args)
+			m.unacked = 0
+			return ok("+CSMS: 1,1,1")
+		}
+		return ok()
+	case "+CNMA":
+		// 仅消息服务为 1 且有未确认的直接上报时可以确认
+		if m.csms != 1 || m.unacked == 0 {
+			return cmsError(cmsNoCnmaExpected)
+		}
+		m.unacked--
+		return ok()
+	case "+CPMS":
+		return m.cpms(op, args)
+	case "+CNMI":
+		return m.cnmiCommand(op, args)
+	case "+CMGL":
+		return m.cmgl(op, args)
+	case "+CMGR":
+		return m.cmgr(args)
+	case "+CMGD":
+		return m.cmgd(args)
+	}
+
+	return m.fail()
+}
+
+// numberType 返回号码类型，国际号码为 145
+func numberType(number string) int {
+	if strings.HasPrefix(number, "+") {
+		return 145
+	}
+	return 129
+}
+
+// cops 查询运营商
+func (m *Modem) cops(op string) result {
+	switch op {
+	case "?":
+		if !m.registered() {
+			return ok("+COPS: 0")
+		}
+		return ok(fmt.Sprintf(`+COPS: 0,2,"%s",7`, m.operator))
+	case "=?":
+		// 搜网耗时较长，延迟返回
+		m.later(3*time.Second, func() {
+			m.urc(fmt.Sprintf(`+COPS: (2,"SIMULATOR","SIM","%s",7),,(0-4),(0-2)`, m.operator), "OK")
+		})
+		return result{}
+	}
+	return ok()
+}
+
+// cusd 发起 USSD 会话，稍后通过 +CUSD 通知返回结果
+func (m *Modem) cusd(op, args string) result {
+	if op != "=" {
+		return ok("+CUSD: 1")
+	}
+	params := splitArgs(args)
+	if len(params) < 2 || params[1] == "" {
+		return ok()
+	}
+	if !m.registered() {
+		return m.fail()
+	}
+	code := params[1]
+	m.later(500*time.Millisecond, func() {
+		m.urc(fmt.Sprintf(`+CUSD: 0,"Simulator %s: balance 100.00",15`, code))
+	})
+	return ok()
+}
+
+// cpms 查询或设置短信存储
+func (m *Modem) cpms(op, args string) result {
+	used := len(m.messages)
+	switch op {
+	case "?":
+		return ok(fmt.Sprintf(`+CPMS: "%s",%d,%d,"%s",%d,%d,"%s",%d,%d`,
+			m.store[0], used, storageCapacity,
+			m.store[1], used, storageCapacity,
+			m.store[2], used, storageCapacity,
+		))
+	case "=?":
+		return ok(`+CPMS: ("ME","SM","MT"),("ME","SM","MT"),("ME","SM","MT")`)
+	case "=":
+		params := splitArgs(args)
+		if len(params) > 3 {
+			return cmsError(cmsOperationNotAllowed)
+		}
+		for _, mem := range params {
+			if mem != "ME" && mem != "SM" && mem != "MT" {
+				return cmsError(cmsOperationNotAllowed)
+			}
+		}
+		for i, mem := range params {
+			m.store[i] = mem
+		}
+		return ok(fmt.Sprintf("+CPMS: %d,%d,%d,%d,%d,%d",
+			used, storageCapacity, used, storageCapacity, used, storageCapacity,
+		))
+	}
+	return m.fail()
+}
+
+// cnmiCommand 查询或设置新短信通知方式
+func (m *Modem) cnmiCommand(op, args string) result {
+	switch op {
+	case "?":
+		c := m.cnmi
+		return ok(fmt.Sprintf("+CNMI: %d,%d,%d,%d,%d", c[0], c[1], c[2], c[3], c[4]))
+	case "=?":
+		return ok("+CNMI: (0-2),(0-3),(0,2),(0-2),(0,1)")
+	case "=":
+		for i, v := range splitArgs(args) {
+			if i >= len(m.cnmi) {
+				break
+			}
+			if v == "" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return cmsError(cmsOperationNotAllowed)
+			}
+			m.cnmi[i] = n
+		}
+	}
+	return ok()
+}
+
+// cmgl 列出指定状态的短信
+func (m *Modem) cmgl(op, args string) result {
+	if op == "=?" {
+		return ok("+CMGL: (0-4)")
+	}
+	if m.cmgf != 0 {
+		return cmsError(cmsOperationNotAllowed)
+	}
+	stat := 4
+	if op == "=" {
+		var err error
+		if stat, err = strconv.Atoi(args); err != nil || stat < 0 || stat > 4 {
+			return cmsError(cmsOperationNotAllowed)
+		}
+	}
+
+	res := ok()
+	for _, idx := range m.indices() {
+		msg := m.messages[idx]
+		if stat != 4 && msg.stat != stat {
+			continue
+		}
+		res.lines = append(res.lines, fmt.Sprintf("+CMGL: %d,%d,,%d", idx, msg.stat, msg.length), msg.pdu)
+		if msg.stat == 0 {
+			msg.stat = 1
+		}
+	}
+	return res
+}
+
+// cmgr 读取单条短信
+func (m *Modem) cmgr(args string) result {
+	if m.cmgf != 0 {
+		return cmsError(cmsOperationNotAllowed)
+	}
+	idx, err := strconv.Atoi(args)
+	if err != nil {
+		return cmsError(cmsInvalidMemoryIndex)
+	}
+	msg, found := m.messages[idx]
+	if !found {
+		return cmsError(cmsInvalidMemoryIndex)
+	}
+	res := ok(fmt.Sprintf("+CMGR: %d,,%d", msg.stat, msg.length), msg.pdu)
+	if msg.stat == 0 {
+		msg.stat = 1
+	}
+	return res
+}
+
+// cmgd 删除短信，flag: 0 指定索引, 1 已读, 2 已读和已发送, 3 除未读外全部, 4 全部
+func (m *Modem) cmgd(args string) result {
+	params := splitArgs(args)
+	idx, err := strconv.Atoi(params[0])
+	if err != nil {
+		return cmsError(cmsInvalidMemoryIndex)
+	}
+	flag := 0
+	if len(params) > 1 {
+		flag, _ = strconv.Atoi(params[1])
+	}
+
+	if flag == 0 {
+		delete(m.messages, idx)
+		return ok()
+	}
+	for i, msg := range m.messages {
+		if flag == 4 || msg.stat == 1 || (flag >= 2 && msg.stat == 3) || (flag == 3 && msg.stat == 2) {
+			delete(m.messages, i)
+		}
+	}
+	return ok()
+}
+
+// cmgs 检查发送参数，返回 TPDU 长度
+func (m *Modem) cmgs(args string) (result, int) {
+	if m.cmgf != 0 {
+		return cmsError(cmsOperationNotAllowed), 0
+	}
+	n, err := strconv.Atoi(splitArgs(args)[0])
+	if err != nil || n <= 0 || n > 164 {
+		return cmsError(cmsInvalidPduParam), 0
+	}
+	return result{}, n
+}
+
+// indices 返回排序后的短信索引
+func (m *Modem) indices() []int {
+	list := make([]int, 0, len(m.messages))
+	for idx := range m.messages {
+		list = append(list, idx)
+	}
+	sort.Ints(list)
+	return list
+}
+
+// freeIndex 返回空闲的存储索引，存储已满时返回 -1
+func (m *Modem) freeIndex() int {
+	for i := 0; i < storageCapacity; i++ {
+		if _, used := m.messages[i]; !used {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,304 @@
+package simulator
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Scheme 模拟器端口前缀，例如 sim://demo
+const Scheme = "sim://"
+
+// 网络注册状态，与 AT+CREG 一致
+const (
+	RegNotRegistered = 0 // 未注册
+	RegHome          = 1 // 已注册本地网络
+	RegSearching     = 2 // 搜索中
+	RegDenied        = 3 // 注册被拒绝
+	RegRoaming       = 5 // 已注册漫游
+)
+
+// storageCapacity 短信存储容量
+const storageCapacity = 50
+
+// ackTimeout 消息服务为 1 时等待 AT+CNMA 确认直接上报的短信或状态报告的时间，超时后停止直接上报
+var ackTimeout = 15 * time.Second
+
+var (
+	modemsMu sync.Mutex
+	modems   = map[string]*Modem{}
+)
+
+// Modem 虚拟调制解调器，状态在端口重新打开后保留
+type Modem struct {
+	name string
+
+	mu       sync.Mutex
+	imei     string
+	imsi     string
+	iccid    string
+	number   string
+	smsc     string
+	operator string
+	rssi     int
+	stat     int
+	creg     int
+	echo     bool
+	cmgf     int
+	cmee     int
+	csms     int
+	unacked  int // 等待 AT+CNMA 确认的直接上报数量
+	store    [3]string
+	cnmi     [5]int
+	messages map[int]*message
+	sent     []SentSms
+	mr       byte
+	port     *Port
+}
+
+// message 存储的短信
+type message struct {
+	stat   int    // 0: REC UNREAD, 1: REC READ, 2: STO UNSENT, 3: STO SENT
+	length int    // TPDU 长度
+	pdu    string // 含短信中心地址的 PDU 十六进制
+}
+
+// SentSms 模拟器收到的待发送短信
+type SentSms struct {
+	MR     int       `json:"mr"`
+	Number string    `json:"number"`
+	Text   string    `json:"text"`
+	SRR    bool      `json:"srr"` // 是否请求状态报告
+	Time   time.Time `json:"time"`
+}
+
+// Status 模拟器状态
+type Status struct {
+	Name         string    `json:"name"`
+	Port         string    `json:"port"`
+	IMEI         string    `json:"imei"`
+	IMSI         string    `json:"imsi"`
+	ICCID        string    `json:"iccid"`
+	Number       string    `json:"number"`
+	Operator     string    `json:"operator"`
+	Signal       int       `json:"signal"`
+	Registration int       `json:"registration"`
+	Service      int       `json:"service"` // 消息服务（AT+CSMS）
+	Connected    bool      `json:"connected"`
+	Stored       int       `json:"stored"`
+	Sent         []SentSms `json:"sent"`
+}
+
+// Get 返回指定名称的模拟器，不存在时创建
+func Get(name string) *Modem {
+	modemsMu.Lock()
+	defer modemsMu.Unlock()
+
+	if m, ok := modems[name]; ok {
+		return m
+	}
+	m := newModem(name)
+	modems[name] = m
+	return m
+}
+
+// Find 返回已创建的模拟器
+func Find(name string) (*Modem, error) {
+	modemsMu.Lock()
+	defer modemsMu.Unlock()
+
+	if m, ok := modems[name]; ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("simulator %s not found", name)
+}
+
+// List 返回所有模拟器的状态
+func List() []Status {
+	modemsMu.Lock()
+	list := make([]*Modem, 0, len(modems))
+	for _, m := range modems {
+		list = append(list, m)
+	}
+	modemsMu.Unlock()
+
+	res := []Status{}
+	for _, m := range list {
+		res = append(res, m.Status())
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// newModem 创建模拟器，身份信息由名称生成，保证每次启动一致
+func newModem(name string) *Modem {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	seed := h.Sum64()
+	digits := fmt.Sprintf("%020d", seed)
+
+	imei := "86" + digits[8:20]
+	return &Modem{
+		name:     name,
+		imei:     imei + luhn(imei),
+		imsi:     "46000" + digits[10:20],
+		iccid:    "898600" + digits[6:20],
+		number:   "+86138" + digits[12:20],
+		smsc:     "+8613800100500",
+		operator: "46000",
+		rssi:     20,
+		stat:     RegHome,
+		echo:     true,
+		store:    [3]string{"ME", "ME", "ME"},
+		cnmi:     [5]int{2, 1, 0, 0, 0},
+		messages: map[int]*message{},
+	}
+}
+
+// luhn 计算 IMEI 校验位
+func luhn(s string) string {
+	sum := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		d := int(s[i] - '0')
+		if (len(s)-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return fmt.Sprint((10 - sum%10) % 10)
+}
+
+// Status 返回模拟器状态
+func (m *Modem) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return Status{
+		Name:         m.name,
+		Port:         Scheme + m.name,
+		IMEI:         m.imei,
+		IMSI:         m.imsi,
+		ICCID:        m.iccid,
+		Number:       m.number,
+		Operator:     m.operator,
+		Signal:       m.rssi,
+		Registration: m.stat,
+		Service:      m.csms,
+		Connected:    m.port != nil,
+		Stored:       len(m.messages),
+		Sent:         append([]SentSms{}, m.sent...),
+	}
+}
+
+// SetSignal 设置信号强度，rssi 为 0-31，99 表示未知
+func (m *Modem) SetSignal(rssi int) error {
+	if (rssi < 0 || rssi > 31) && rssi != 99 {
+		return fmt.Errorf("invalid rssi: %d", rssi)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rssi = rssi
+	return nil
+}
+
+// SetRegistration 设置网络注册状态和运营商，开启 AT+CREG 通知时上报
+func (m *Modem) SetRegistration(stat int, operator string) error {
+	if stat < RegNotRegistered || stat > RegRoaming {
+		return fmt.Errorf("invalid registration state: %d", stat)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stat = stat
+	if operator != "" {
+		m.operator = operator
+	}
+	if m.creg > 0 {
+		m.urc(fmt.Sprintf("+CREG: %d", stat))
+	}
+	return nil
+}
+
+// registered 是否已注册网络，调用方需持有锁
+func (m *Modem) registered() bool {
+	return m.stat == RegHome || m.stat == RegRoaming
+}
+
+// attach 绑定新打开的端口，关闭之前的端口
+func (m *Modem) attach(p *Port) {
+	m.mu.Lock()
+	old := m.port
+	m.port = p
+	m.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+}
+
+// detach 解除端口绑定
+func (m *Modem) detach(p *Port) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.port == p {
+		m.port = nil
+	}
+}
+
+// SetService 设置消息服务（AT+CSMS），1 表示直接上报的短信和状态报告需要 AT+CNMA 确认
+func (m *Modem) SetService(service int) error {
+	if service < 0 || service > 1 {
+		return fmt.Errorf("invalid service: %d", service)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.csms, m.unacked = service, 0
+	return nil
+}
+
+// directUrc 发送直接上报的短信或状态报告，消息服务为 1 时需要 AT+CNMA 确认，
+// 超时未确认时与 3GPP TS 27.005 一致将 AT+CNMI 的 mt 和 ds 设为 0，调用方需持有锁
+func (m *Modem) directUrc(lines ...string) {
+	m.urc(lines...)
+	if m.csms != 1 || m.port == nil {
+		return
+	}
+	m.unacked++
+	m.later(ackTimeout, func() {
+		if m.unacked == 0 {
+			return
+		}
+		m.unacked = 0
+		m.cnmi[1], m.cnmi[3] = 0, 0
+	})
+}
+
+// urc 向当前端口发送通知，调用方需持有锁
+func (m *Modem) urc(lines ...string) {
+	if m.port == nil {
+		return
+	}
+	for _, line := range lines {
+		m.port.send("\r\n" + line + "\r\n")
+	}
+}
+
+// later 延迟执行，模拟网络侧的响应时间
+func (m *Modem) later(d time.Duration, fn func()) {
+	time.AfterFunc(d, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		fn()
+	})
+}
@@ -0,0 +1,127 @@
+package simulator
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPort 按行读取模拟器输出的测试端口
+type testPort struct {
+	*Port
+	lines chan string
+}
+
+func openTestPort(t *testing.T, name string) *testPort {
+	t.Helper()
+	p, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := &testPort{Port: p, lines: make(chan string, 64)}
+	t.Cleanup(func() { p.Close() })
+
+	go func() {
+		r := bufio.NewReader(p)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(tp.lines)
+				return
+			}
+			if line = strings.TrimSpace(line); line != "" {
+				tp.lines <- line
+			}
+		}
+	}()
+
+	tp.command(t, "ATE0")
+	return tp
+}
+
+// next 读取下一行输出
+func (p *testPort) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case line := <-p.lines:
+		return line
+	case <-time.After(time.Second):
+		t.Fatal("no output from simulator")
+		return ""
+	}
+}
+
+// command 执行命令，返回包括结果在内的所有响应行
+func (p *testPort) command(t *testing.T, cmd string) []string {
+	t.Helper()
+	p.Write([]byte(cmd + "\r"))
+	resp := []string{}
+	for {
+		line := p.next(t)
+		resp = append(resp, line)
+		if line == "OK" || strings.Contains(line, "ERROR") {
+			return resp
+		}
+	}
+}
+
+func TestDirectUrcAck(t *testing.T) {
+	defer func(d time.Duration) { ackTimeout = d }(ackTimeout)
+	ackTimeout = 100 * time.Millisecond
+
+	m := Get("test-ack")
+	if err := m.SetService(1); err != nil {
+		t.Fatal(err)
+	}
+	p := openTestPort(t, "test-ack")
+	p.command(t, "AT+CNMI=2,2,0,1,0")
+
+	// 没有待确认的上报时不能确认
+	if resp := p.command(t, "AT+CNMA"); resp[len(resp)-1] != "+CMS ERROR: 340" {
+		t.Fatalf("CNMA without pending urc: %q", resp)
+	}
+
+	// 确认后保持直接上报
+	if err := m.InjectSms("+8613800000000", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if line := p.next(t); !strings.HasPrefix(line, "+CMT:") {
+		t.Fatalf("want +CMT, got %q", line)
+	}
+	p.next(t) // PDU
+	if resp := p.command(t, "AT+CNMA"); resp[len(resp)-1] != "OK" {
+		t.Fatalf("CNMA: %q", resp)
+	}
+	time.Sleep(2 * ackTimeout)
+	if resp := p.command(t, "AT+CNMI?"); resp[0] != "+CNMI: 2,2,0,1,0" {
+		t.Fatalf("routing after ack: %q", resp)
+	}
+
+	// 超时未确认时停止直接上报
+	if err := m.InjectSms("+8613800000000", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	p.next(t)
+	p.next(t)
+	time.Sleep(2 * ackTimeout)
+	if resp := p.command(t, "AT+CNMI?"); resp[0] != "+CNMI: 2,0,0,0,0" {
+		t.Fatalf("routing without ack: %q", resp)
+	}
+}
+
+func TestDirectUrcNoAck(t *testing.T) {
+	m := Get("test-noack")
+	p := openTestPort(t, "test-noack")
+	p.command(t, "AT+CNMI=2,2,0,1,0")
+
+	// 消息服务为 0 时不需要也不能确认
+	if err := m.InjectSms("+8613800000000", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	p.next(t)
+	p.next(t)
+	if resp := p.command(t, "AT+CNMA"); resp[len(resp)-1] != "+CMS ERROR: 340" {
+		t.Fatalf("CNMA with service 0: %q", resp)
+	}
+}
@@ -0,0 +1,97 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Port 模拟器端口，实现 at.Port 接口
+type Port struct {
+	modem *Modem
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	out    []byte
+	closed bool
+
+	// 以下字段仅在持有 modem.mu 时访问
+	line    []byte // 正在输入的命令
+	pduMode bool   // 正在输入 PDU
+	pduLen  int    // AT+CMGS 指定的 TPDU 长度
+}
+
+// Open 打开指定名称的模拟器端口，名称可带 sim:// 前缀
+func Open(name string) (*Port, error) {
+	name = strings.TrimPrefix(name, Scheme)
+	if name == "" {
+		return nil, fmt.Errorf("simulator name is empty")
+	}
+
+	p := &Port{modem: Get(name)}
+	p.cond = sync.NewCond(&p.mu)
+	p.modem.attach(p)
+	return p, nil
+}
+
+// Read 读取模拟器输出，无数据时阻塞
+func (p *Port) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.out) == 0 && !p.closed {
+		p.cond.Wait()
+	}
+	if len(p.out) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, p.out)
+	p.out = p.out[n:]
+	return n, nil
+}
+
+// Write 向模拟器写入命令
+func (p *Port) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+
+	if closed {
+		return 0, fmt.Errorf("port closed")
+	}
+	p.modem.input(p, b)
+	return len(b), nil
+}
+
+// Flush 无需刷新
+func (p *Port) Flush() error {
+	return nil
+}
+
+// Close 关闭端口
+func (p *Port) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	p.modem.detach(p)
+	return nil
+}
+
+// send 写入模拟器输出
+func (p *Port) send(s string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return
+	}
+	p.out = append(p.out, s...)
+	p.cond.Broadcast()
+}
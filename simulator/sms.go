@@ -0,0 +1,170 @@
+package simulator
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rehiy/modem/sms"
+	"github.com/rehiy/modem/sms/pdumode"
+	"github.com/rehiy/modem/sms/tpdu"
+)
+
+// sentLimit 保留的已发送短信数量
+const sentLimit = 100
+
+// reportDelay 状态报告的延迟时间
+var reportDelay = 2 * time.Second
+
+// InjectSms 模拟收到短信，按 AT+CNMI 设置存储或直接上报
+func (m *Modem) InjectSms(from, text string) error {
+	return m.InjectSmsParts(from, text, 0, nil)
+}
+
+// InjectSmsParts 模拟收到长短信，各分段间隔 interval 到达，drop 中的分段序号（从 1 开始）不会到达
+func (m *Modem) InjectSmsParts(from, text string, interval time.Duration, drop []int) error {
+	if from == "" || text == "" {
+		return fmt.Errorf("from and text are required")
+	}
+
+	tpdus, err := sms.Encode([]byte(text), sms.AsDeliver, sms.From(from))
+	if err != nil {
+		return fmt.Errorf("failed to encode sms: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.registered() {
+		return fmt.Errorf("simulator %s is not registered", m.name)
+	}
+	if m.cnmi[1] != 2 && len(m.messages)+len(tpdus) > storageCapacity {
+		return fmt.Errorf("simulator %s storage is full", m.name)
+	}
+
+	now := time.Now()
+	delay := time.Duration(0)
+	for i, t := range tpdus {
+		if slices.Contains(drop, i+1) {
+			continue
+		}
+		t.SCTS = tpdu.Timestamp{Time: now}
+		pdu, length, err := m.marshalPdu(&t)
+		if err != nil {
+			return err
+		}
+		if delay == 0 {
+			m.deliver(pdu, length)
+		} else {
+			m.later(delay, func() { m.deliver(pdu, length) })
+		}
+		delay += interval
+	}
+	return nil
+}
+
+// deliver 按 AT+CNMI 的 mt 参数处理收到的短信，调用方需持有锁
+func (m *Modem) deliver(pdu string, length int) {
+	switch m.cnmi[1] {
+	case 2:
+		m.directUrc(fmt.Sprintf("+CMT: ,%d", length), pdu)
+	case 0:
+		m.save(0, pdu, length)
+	default:
+		if idx := m.save(0, pdu, length); idx >= 0 {
+			m.urc(fmt.Sprintf(`+CMTI: "%s",%d`, m.store[2], idx))
+		}
+	}
+}
+
+// save 存储短信，返回索引，存储已满时返回 -1，调用方需持有锁
+func (m *Modem) save(stat int, pdu string, length int) int {
+	idx := m.freeIndex()
+	if idx >= 0 {
+		m.messages[idx] = &message{stat: stat, length: length, pdu: pdu}
+	}
+	return idx
+}
+
+// marshalPdu 编码 TPDU，附加短信中心地址，调用方需持有锁
+func (m *Modem) marshalPdu(t *tpdu.TPDU) (string, int, error) {
+	b, err := t.MarshalBinary()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to marshal tpdu: %w", err)
+	}
+	p := pdumode.PDU{
+		SMSC: pdumode.SmscAddress{Address: tpdu.NewAddress(tpdu.FromNumber(m.smsc))},
+		TPDU: b,
+	}
+	s, err := p.MarshalHexString()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to marshal pdu: %w", err)
+	}
+	return strings.ToUpper(s), len(b), nil
+}
+
+// submit 处理 AT+CMGS 提交的 PDU，调用方需持有锁
+func (m *Modem) submit(pdu string, length int) result {
+	if !m.registered() {
+		return cmsError(cmsNoNetworkService)
+	}
+
+	p, err := pdumode.UnmarshalHexString(pdu)
+	if err != nil || len(p.TPDU) != length {
+		return cmsError(cmsInvalidPduParam)
+	}
+	t, err := sms.Unmarshal(p.TPDU, sms.AsMO)
+	if err != nil || t.SmsType() != tpdu.SmsSubmit {
+		return cmsError(cmsInvalidPduParam)
+	}
+	text, err := sms.Decode([]*tpdu.TPDU{t})
+	if err != nil {
+		return cmsError(cmsInvalidPduParam)
+	}
+
+	m.mr++
+	mr := m.mr
+	m.sent = append(m.sent, SentSms{
+		MR:     int(mr),
+		Number: t.DA.Number(),
+		Text:   string(text),
+		SRR:    t.FirstOctet.SRR(),
+		Time:   time.Now(),
+	})
+	if len(m.sent) > sentLimit {
+		m.sent = m.sent[len(m.sent)-sentLimit:]
+	}
+
+	if t.FirstOctet.SRR() {
+		da := t.DA
+		m.later(reportDelay, func() { m.report(mr, da) })
+	}
+	return ok(fmt.Sprintf("+CMGS: %d", mr))
+}
+
+// report 生成投递成功的状态报告，按 AT+CNMI 的 ds 参数上报或存储，调用方需持有锁
+func (m *Modem) report(mr byte, ra tpdu.Address) {
+	t := tpdu.TPDU{}
+	t.SetSmsType(tpdu.SmsStatusReport)
+	t.MR = mr
+	t.RA = ra
+	now := time.Now()
+	t.SCTS = tpdu.Timestamp{Time: now.Add(-reportDelay)}
+	t.DT = tpdu.Timestamp{Time: now}
+	t.ST = 0 // 已投递
+
+	pdu, length, err := m.marshalPdu(&t)
+	if err != nil {
+		return
+	}
+
+	switch m.cnmi[3] {
+	case 1:
+		m.directUrc(fmt.Sprintf("+CDS: %d", length), pdu)
+	case 2:
+		if idx := m.save(0, pdu, length); idx >= 0 {
+			m.urc(fmt.Sprintf(`+CDSI: "%s",%d`, m.store[2], idx))
+		}
+	}
+}
@@ -23,38 +23,64 @@ export class ModemManager {
 
     /**
      * 刷新Modem列表
-     * 获取所有可用的Modem设备并更新选择框
+     * 获取所有可用的Modem设备并更新选择框，后台扫描完成后再次更新
      */
     async refreshModems() {
         try {
-            const modems = await apiRequest('/modem/list');
-            const select = $('#modemSelect');
-            const current = select.value;
-            select.innerHTML = '<option value="">-- 选择串口 --</option>';
-
-            modems.forEach(modem => {
-                const option = document.createElement('option');
-                option.value = modem.name;
-                option.textContent = modem.name + (modem.connected ? ' (已连接)' : '(已断开)');
-                select.appendChild(option);
-            });
-
-            if (current && modems.find(p => p.name === current && p.connected)) {
-                select.value = current;
-            } else {
-                const connected = modems.find(p => p.connected);
-                if (connected) select.value = connected.name;
-            }
-
-            this.name = $('#modemSelect').value;
-            await this.getModemInfo();
-            await this.getSignalStrength();
+            const res = await apiRequest('/modem/list');
+            await this.renderModems(res.modems || []);
             app.logger.info('已刷新串口列表');
+            if (res.scan_id) {
+                await this.waitScanJob(res.scan_id);
+                const done = await apiRequest('/modem/list?scan=false');
+                await this.renderModems(done.modems || []);
+                app.logger.info('设备扫描完成');
+            }
         } catch (error) {
             app.logger.error('刷新串口失败: ' + error);
         }
     }
 
+    /**
+     * 等待扫描任务完成
+     * @param {string} id - 扫描任务ID
+     */
+    async waitScanJob(id) {
+        for (let i = 0; i < 60; i++) {
+            const job = await apiRequest(`/modem/scan?${buildQueryString({ id })}`);
+            if (job.status === 'done') return job;
+            await new Promise(resolve => setTimeout(resolve, 1000));
+        }
+    }
+
+    /**
+     * 渲染Modem列表
+     * @param {Array} modems - Modem列表
+     */
+    async renderModems(modems) {
+        const select = $('#modemSelect');
+        const current = select.value;
+        select.innerHTML = '<option value="">-- 选择串口 --</option>';
+
+        modems.forEach(modem => {
+            const option = document.createElement('option');
+            option.value = modem.name;
+            option.textContent = (modem.alias || modem.name) + (modem.connected ? ' (已连接)' : '(已断开)');
+            select.appendChild(option);
+        });
+
+        if (current && modems.find(p => p.name === current && p.connected)) {
+            select.value = current;
+        } else {
+            const connected = modems.find(p => p.connected);
+            if (connected) select.value = connected.name;
+        }
+
+        this.name = select.value;
+        await this.getModemInfo();
+        await this.getSignalStrength();
+    }
+
     /**
      * 获取Modem信息
      * 获取当前Modem的设备信息
@@ -210,12 +236,21 @@ export class ModemManager {
             return;
         }
 
+        // 定时发送
+        const body = { name: this.name, number, message, transliterate: $('#smsTransliterate').checked };
+        const sendAt = $('#smsSendAt').value;
+        if (sendAt) {
+            body.send_at = new Date(sendAt).toISOString();
+            body.late_policy = $('#smsLatePolicy').value;
+        }
+
         try {
             app.logger.info('正在发送短信 ...');
-            await apiRequest('/modem/sms/send', 'POST', { name: this.name, number, message });
-            app.logger.success('短信发送成功', number);
+            const res = await apiRequest('/modem/sms/send', 'POST', body);
+            app.logger.success(`短信已加入发送队列 (任务 ${res.job_id})`, number);
             $('#smsNumber').value = '';
             $('#smsMessage').value = '';
+            $('#smsSendAt').value = '';
             this.updateSmsCounter();
         } catch (error) {
             app.logger.error('发送短信失败: ' + error);
@@ -256,20 +291,47 @@ export class ModemManager {
 
     /**
      * 更新短信计数器
-     * 根据短信内容计算字符数、编码方式和短信条数
+     * 输入停止后由服务端计算编码方式、短信条数和无法用 GSM-7 编码的字符
      */
     updateSmsCounter() {
+        clearTimeout(this.counterTimer);
+        this.counterTimer = setTimeout(() => this.renderSmsCounter(), 300);
+    }
+
+    /**
+     * 渲染短信计数器
+     */
+    async renderSmsCounter() {
         const textarea = $('#smsMessage');
         const counter = $('#smsCounter');
         if (!textarea || !counter) return;
 
         const message = textarea.value;
-        const hasUnicode = /[^\x00-\x7F]/.test(message);
-        const maxChars = hasUnicode ? (message.length <= 70 ? 70 : 67) : (message.length <= 160 ? 160 : 153);
-        const parts = Math.ceil(message.length / maxChars) || 1;
-        const encoding = hasUnicode ? 'UCS2 (中文)' : 'GSM 7-bit';
+        if (!message) {
+            counter.style.color = '#666';
+            counter.innerHTML = '<span>字符数: 0 / 160</span> | <span>短信条数: 1</span> | <span>编码: GSM 7-bit</span>';
+            return;
+        }
 
-        let counterHtml = `<span>字符数: ${message.length} / ${maxChars}</span> | <span>短信条数: ${parts}</span> | <span>编码: ${encoding}</span>`;
+        let preview;
+        try {
+            preview = await apiRequest('/modem/sms/preview', 'POST', {
+                message, transliterate: $('#smsTransliterate').checked
+            });
+        } catch (error) {
+            counter.innerHTML = `<span>计算失败: ${error}</span>`;
+            return;
+        }
+
+        const parts = preview.segments.length;
+        const last = preview.segments[parts - 1];
+        const encoding = preview.encoding === 'ucs2' ? 'UCS2 (中文)' : 'GSM 7-bit';
+
+        let counterHtml = `<span>字符数: ${preview.characters}</span> | <span>本条剩余: ${last.remaining}</span> | <span>短信条数: ${parts}</span> | <span>编码: ${encoding}</span>`;
+        if (preview.encoding === 'ucs2' && preview.non_gsm.length > 0) {
+            const chars = preview.non_gsm.slice(0, 10).map(c => c.replace(/[<>&]/g, '')).join(' ');
+            counterHtml += ` | <span>非 GSM 字符: ${chars}</span>`;
+        }
 
         if (parts > 3) {
             counter.style.color = '#ff4444';
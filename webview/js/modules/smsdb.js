@@ -88,7 +88,7 @@ export class SmsdbManager {
 
         tbody.innerHTML = smsList.map(sms => app.render.render('smsdbItem', {
             id: sms.id,
-            direction: sms.direction === 'in' ? '📥 接收' : '📤 发送',
+            direction: sms.direction === 'in' ? '📥 接收' + (sms.partial ? '（不完整）' : '') : '📤 发送' + this.formatStatus(sms),
             send_number: sms.send_number || '-',
             receive_number: sms.receive_number || '-',
             content: sms.content,
@@ -97,6 +97,25 @@ export class SmsdbManager {
         })).join('');
     }
 
+    /**
+     * 格式化发送短信的状态
+     * @param {Object} sms - 短信数据
+     * @returns {string} 状态说明
+     */
+    formatStatus(sms) {
+        const names = {
+            queued: '等待发送',
+            sending: '发送中',
+            sent: '已发送',
+            failed: '发送失败',
+            delivered: '已送达',
+            undeliverable: '无法送达',
+            cancelled: '已取消',
+            expired: '已过期',
+        };
+        return sms.status ? `（${names[sms.status] || sms.status}）` : '';
+    }
+
     toggleSmsdbSelection(id) {
         if (this.selectedSmsdb.has(id)) {
             this.selectedSmsdb.delete(id);
@@ -16,6 +16,8 @@ export class WebhookManager {
         this.currentWebhookId = null;
         // 初始化预设模板选项
         this.initPresetTemplates();
+        // 默认订阅收到短信
+        this.setEvents('');
     }
 
     /**
@@ -88,6 +90,7 @@ export class WebhookManager {
             $('#webhookURL').value = webhook.url;
             $('#webhookTemplate').value = webhook.template;
             $('#webhookEnabledCheckbox').checked = webhook.enabled;
+            this.setEvents(webhook.events);
             $('#webhookTemplateSelect').value = 'custom';
         } catch (error) {
             app.logger.error('加载 Webhook 详情失败: ' + error);
@@ -101,9 +104,31 @@ export class WebhookManager {
         $('#webhookURL').value = '';
         $('#webhookTemplate').value = '{}';
         $('#webhookEnabledCheckbox').checked = true;
+        this.setEvents('');
         $('#webhookTemplateSelect').value = 'custom';
     }
 
+    /**
+     * 设置订阅的事件
+     * @param {string} events - 逗号分隔的事件，为空时只订阅收到短信
+     */
+    setEvents(events) {
+        const list = (events || 'sms_received').split(',');
+        $('#webhookEventReceived').checked = list.includes('sms_received');
+        $('#webhookEventStatus').checked = list.includes('sms_status');
+    }
+
+    /**
+     * 获取订阅的事件
+     * @returns {string} 逗号分隔的事件
+     */
+    getEvents() {
+        const list = [];
+        if ($('#webhookEventReceived').checked) list.push('sms_received');
+        if ($('#webhookEventStatus').checked) list.push('sms_status');
+        return list.join(',');
+    }
+
     /**
      * 应用预设模板
      * 当用户从下拉框选择预设模板时，自动填充模板内容
@@ -138,12 +163,18 @@ export class WebhookManager {
         const url = $('#webhookURL').value.trim();
         const template = $('#webhookTemplate').value.trim();
         const enabled = $('#webhookEnabledCheckbox').checked;
+        const events = this.getEvents();
 
         if (!name || !url) {
             app.logger.error('请填写名称和 URL');
             return;
         }
 
+        if (!events) {
+            app.logger.error('请至少选择一个事件');
+            return;
+        }
+
         // 验证模板是否为有效的JSON
         if (template && template !== '{}') {
             try {
@@ -155,7 +186,7 @@ export class WebhookManager {
         }
 
         try {
-            const webhookData = { name, url, template, enabled };
+            const webhookData = { name, url, template, enabled, events };
 
             if (this.currentWebhookId) {
                 const queryString = buildQueryString({ id: this.currentWebhookId });